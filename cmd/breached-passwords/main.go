@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/repositories"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+// Loads breached password hashes into BREACHED_PASSWORD_DIR without any
+// network access. Each input line is a SHA-1 hash, optionally followed by
+// ":<COUNT>", or a plain password with -plain.
+func main() {
+	plain := flag.Bool("plain", false, "input lines are plain passwords to hash")
+	flag.Parse()
+
+	if err := config.InitializeAppConfig(); err != nil {
+		panic(fmt.Errorf("fatal error config file: %w", err))
+	}
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	if *plain {
+		input = hashLines(input)
+	}
+
+	imported, err := repositories.NewFileBreachedPasswordRepository().Import(input)
+	if err != nil {
+		log.Fatalf("import failed after %d hashes: %v", imported, err)
+	}
+
+	log.Printf("imported %d breached password hashes into %s", imported, config.AppConfig.BreachedPasswordDir)
+}
+
+// hashLines replaces every line with its SHA-1 hash.
+func hashLines(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+
+	go func() {
+		w := bufio.NewWriter(pw)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			sum := sha1.Sum(scanner.Bytes())
+			fmt.Fprintln(w, hex.EncodeToString(sum[:]))
+		}
+		if err := scanner.Err(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+
+	return pr
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"github.com/Joe5451/go-oauth2-server/internal"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/config"
 
 	"fmt"
+	"log"
+	"time"
 )
 
 func main() {
@@ -12,10 +15,32 @@ func main() {
 		panic(fmt.Errorf("fatal error config file: %w", err))
 	}
 
-	router, err := internal.InitializeApp()
+	app, cleanup, err := internal.InitializeApp()
 	if err != nil {
 		panic(err)
 	}
+	defer cleanup()
 
-	router.Run("localhost:8080")
+	go runAccountPurger(app.Purger)
+
+	app.Router.Run("localhost:8080")
+}
+
+// runAccountPurger periodically purges users whose deletion grace period has
+// passed.
+func runAccountPurger(purger in.AccountPurgeUsecase) {
+	interval := config.AppConfig.AccountPurgeInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	for ; ; time.Sleep(interval) {
+		purged, err := purger.PurgeDeletedUsers()
+		if err != nil {
+			log.Printf("account purge failed: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted users", purged)
+		}
+	}
 }
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	usecase in.AccountUnlockUsecase
+}
+
+func NewAccountHandler(usecase in.AccountUnlockUsecase) *AccountHandler {
+	return &AccountHandler{
+		usecase: usecase,
+	}
+}
+
+func (h *AccountHandler) UnlockAccount(c *gin.Context) {
+	json := struct {
+		Token string `json:"token" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.UnlockAccount(json.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
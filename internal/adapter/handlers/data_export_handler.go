@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type DataExportHandler struct {
+	usecase in.DataExportUsecase
+}
+
+func NewDataExportHandler(usecase in.DataExportUsecase) *DataExportHandler {
+	return &DataExportHandler{
+		usecase: usecase,
+	}
+}
+
+// RequestExport reports the status of the user's data export, starting one if
+// needed. Clients poll it until the download link is available.
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	result, err := h.usecase.RequestDataExport(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Status != domain.DataExportReady {
+		status = http.StatusAccepted
+	}
+
+	c.JSON(status, gin.H{
+		"status":       result.Status,
+		"download_url": result.DownloadUrl,
+		"expires_at":   result.ExpiresAt,
+	})
+}
+
+// Download serves the archive behind a signed link. The link itself is the
+// credential, so no session is required.
+func (h *DataExportHandler) Download(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.Error(fmt.Errorf("%w: token is required", ErrValidation))
+		return
+	}
+
+	path, err := h.usecase.OpenDataExport(token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.FileAttachment(path, "user-data-export.zip")
+}
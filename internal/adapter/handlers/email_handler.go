@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-gonic/gin"
+)
+
+type EmailHandler struct {
+	usecase in.EmailVerificationUsecase
+}
+
+func NewEmailHandler(usecase in.EmailVerificationUsecase) *EmailHandler {
+	return &EmailHandler{
+		usecase: usecase,
+	}
+}
+
+func (h *EmailHandler) VerifyEmail(c *gin.Context) {
+	json := struct {
+		Token string `json:"token" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.VerifyEmail(json.Token); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *EmailHandler) ResendVerificationEmail(c *gin.Context) {
+	json := struct {
+		Email string `json:"email" binding:"required,email"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.ResendVerificationEmail(json.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
+)
+
+// ErrorCode is how an error is reported to the client: the status and code of
+// the API response, and the code a failed redirect login redirects with.
+type ErrorCode struct {
+	Err    error
+	Status int
+	Code   string
+	// Message is the message of the API response. Empty uses the message of
+	// the error itself.
+	Message string
+}
+
+// ErrorCodes are the errors reported to the client. Errors not listed are
+// reported as INTERNAL_ERROR.
+var ErrorCodes = []ErrorCode{
+	{ErrValidation, http.StatusBadRequest, "VALIDATION_ERROR", ""},
+	{domain.ErrPasswordPolicy, http.StatusBadRequest, "VALIDATION_ERROR", ""},
+	{ErrUnauthorized, http.StatusUnauthorized, "UNAUTHORIZED", "Requires authentication."},
+	{ErrInvalidState, http.StatusBadRequest, "INVALID_STATE", ""},
+	{ErrRedirectURINotAllowed, http.StatusBadRequest, "REDIRECT_URI_NOT_ALLOWED", ""},
+	{ErrMissingFile, http.StatusUnauthorized, "MISSING_FILE", ""},
+	{ErrInvalidFileFormat, http.StatusUnauthorized, "INVALID_FILE_FORMAT", ""},
+	{domain.ErrUserNotFound, http.StatusNotFound, "USER_NOT_FOUND", "The user does not exist."},
+	{domain.ErrInvalidCredentials, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Incorrect email or password."},
+	{domain.ErrDuplicateEmail, http.StatusConflict, "DUPLICATE_EMAIL", "The email is already in use."},
+	{socialproviders.ErrInvalidProvider, http.StatusBadRequest, "INVALID_SOCIAL_PROVIDER", ""},
+	{domain.ErrInvalidLinkToken, http.StatusBadRequest, "INVALID_LINK_TOKEN", ""},
+	{domain.ErrMismatchedLinkedUser, http.StatusConflict, "MISMATCHED_LINKED_USER", "The linked social account belongs to a different user."},
+	{domain.ErrSocialAccountAlreadyLinked, http.StatusConflict, "SOCIAL_ACCOUNT_ALREADY_LINKED", "The social account has already been linked to another user."},
+	{domain.ErrSocialAccountAlreadyUnlinked, http.StatusConflict, "SOCIAL_ACCOUNT_ALREADY_UNLINKED", "The social account has either not been linked or has already been unlinked."},
+	{domain.ErrUnverifiedSocialEmail, http.StatusConflict, "UNVERIFIED_SOCIAL_EMAIL", "The email is already in use and the provider has not verified it. Sign in to the existing account and link this provider instead."},
+	{domain.ErrEmailNotVerified, http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Please verify your email address before logging in."},
+	{domain.ErrInvalidVerificationToken, http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", "The verification link is invalid or has expired."},
+	{domain.ErrInvalidPasswordResetToken, http.StatusBadRequest, "INVALID_PASSWORD_RESET_TOKEN", "The password reset link is invalid or has expired."},
+	{domain.ErrIncorrectPassword, http.StatusBadRequest, "INCORRECT_PASSWORD", "The current password is incorrect."},
+	{domain.ErrReauthRequired, http.StatusUnauthorized, "REAUTH_REQUIRED", "Please sign in again to continue."},
+	{domain.ErrUserModified, http.StatusConflict, "USER_MODIFIED", "The user has been modified by another request. Reload and try again."},
+	{domain.ErrInvalidExportToken, http.StatusForbidden, "INVALID_EXPORT_TOKEN", "The download link is invalid or has expired."},
+	{domain.ErrInvalidMFACode, http.StatusUnauthorized, "INVALID_MFA_CODE", "The authentication code is invalid or has already been used."},
+	{domain.ErrTOTPNotEnrolled, http.StatusBadRequest, "TOTP_NOT_ENROLLED", "Two-factor authentication has not been set up."},
+	{domain.ErrTOTPAlreadyEnabled, http.StatusConflict, "TOTP_ALREADY_ENABLED", "Two-factor authentication is already enabled."},
+	{domain.ErrWebAuthnFailed, http.StatusBadRequest, "PASSKEY_FAILED", "The passkey could not be verified."},
+	{domain.ErrWebAuthnCredentialNotFound, http.StatusNotFound, "PASSKEY_NOT_FOUND", "Passkey not found."},
+	{domain.ErrInvalidMagicLink, http.StatusBadRequest, "INVALID_MAGIC_LINK", "The login link is invalid or has expired."},
+	{domain.ErrMagicLinkDeviceMismatch, http.StatusForbidden, "MAGIC_LINK_DEVICE_MISMATCH", "Open the login link in the browser it was requested from."},
+	{domain.ErrAccountLocked, http.StatusLocked, "ACCOUNT_LOCKED", "The account is temporarily locked after too many failed attempts. Check your email to unlock it."},
+	{domain.ErrTooManyAttempts, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed attempts. Please wait before trying again."},
+	{domain.ErrInvalidUnlockToken, http.StatusBadRequest, "INVALID_UNLOCK_TOKEN", "The unlock link is invalid or has expired."},
+	{domain.ErrSessionNotFound, http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found."},
+	{domain.ErrLastLoginMethod, http.StatusConflict, "LAST_LOGIN_METHOD", "This is the only way left to sign in. Add a password, passkey or another social account first."},
+	{socialproviders.ErrOAuth2RetrieveError, http.StatusBadRequest, "OAUTH2_RETRIEVE_ERROR", ""},
+	{socialproviders.ErrInvalidNonce, http.StatusBadRequest, "INVALID_NONCE", "The id_token nonce does not match the login request."}}
+
+// LookupErrorCode returns the first entry of ErrorCodes that err matches.
+func LookupErrorCode(err error) (ErrorCode, bool) {
+	for _, e := range ErrorCodes {
+		if errors.Is(err, e.Err) {
+			return e, true
+		}
+	}
+	return ErrorCode{}, false
+}
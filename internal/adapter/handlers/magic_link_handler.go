@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MagicLinkHandler struct {
+	usecase        in.MagicLinkUsecase
+	sessionManager *SessionManager
+}
+
+func NewMagicLinkHandler(usecase in.MagicLinkUsecase, sessionManager *SessionManager) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		usecase:        usecase,
+		sessionManager: sessionManager,
+	}
+}
+
+func (h *MagicLinkHandler) RequestMagicLink(c *gin.Context) {
+	json := struct {
+		Email string `json:"email" binding:"required,email"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	// Links are bound to a random token kept in this browser's session, so a
+	// forwarded link cannot sign in anyone else.
+	session := sessions.Default(c)
+	deviceToken, ok := session.Get("magic_link_device").(string)
+	if !ok || deviceToken == "" {
+		deviceToken = uuid.New().String()
+		session.Set("magic_link_device", deviceToken)
+		session.Save()
+	}
+
+	if err := h.usecase.RequestMagicLink(json.Email, deviceToken, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MagicLinkHandler) VerifyMagicLink(c *gin.Context) {
+	json := struct {
+		Token string `json:"token" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	session := sessions.Default(c)
+	deviceToken, _ := session.Get("magic_link_device").(string)
+
+	user, err := h.usecase.VerifyMagicLink(json.Token, deviceToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	mfaRequired, err := h.sessionManager.SignIn(c, session, user, false)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	if mfaRequired {
+		c.JSON(http.StatusOK, gin.H{"code": in.AuthMFARequired})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
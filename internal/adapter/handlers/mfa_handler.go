@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	usecase        in.MFAUsecase
+	sessionManager *SessionManager
+}
+
+func NewMFAHandler(usecase in.MFAUsecase, sessionManager *SessionManager) *MFAHandler {
+	return &MFAHandler{
+		usecase:        usecase,
+		sessionManager: sessionManager,
+	}
+}
+
+// LoginMFA finishes a login that is waiting for the user's second factor.
+func (h *MFAHandler) LoginMFA(c *gin.Context) {
+	json := struct {
+		Code string `json:"code" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	session := sessions.Default(c)
+	userID, ok := pendingMFAUser(session)
+	if !ok {
+		c.Error(ErrUnauthorized)
+		return
+	}
+
+	if err := h.usecase.VerifyMFA(userID, json.Code, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sessionManager.Start(c, session, userID); err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
+// Reauthenticate confirms the identity of the signed-in user with their second
+// factor so that the session may perform sensitive operations again.
+func (h *MFAHandler) Reauthenticate(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Code string `json:"code" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.VerifyMFA(userID, json.Code, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.sessionManager.Reauthenticated(session)
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	status, err := h.usecase.GetMFAStatus(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totp_enabled":             status.TOTPEnabled,
+		"recovery_codes_remaining": status.RecoveryCodesRemaining,
+	})
+}
+
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	enrollment, err := h.usecase.EnrollTOTP(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      enrollment.Secret,
+		"otpauth_uri": enrollment.OtpauthURI,
+		"qr_code":     enrollment.QRCode,
+	})
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Code string `json:"code" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	codes, err := h.usecase.ConfirmTOTP(userID, json.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Code string `json:"code" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.DisableTOTP(userID, json.Code, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Code string `json:"code" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	codes, err := h.usecase.RegenerateRecoveryCodes(userID, json.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// Session keys holding the challenge of a passkey ceremony in progress.
+const (
+	passkeyRegistrationSession = "passkey_registration"
+	passkeyLoginSession        = "passkey_login"
+	passkeyMFASession          = "passkey_mfa"
+)
+
+type PasskeyHandler struct {
+	usecase        in.PasskeyUsecase
+	sessionManager *SessionManager
+}
+
+func NewPasskeyHandler(usecase in.PasskeyUsecase, sessionManager *SessionManager) *PasskeyHandler {
+	return &PasskeyHandler{
+		usecase:        usecase,
+		sessionManager: sessionManager,
+	}
+}
+
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	passkeys, err := h.usecase.ListPasskeys(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+func (h *PasskeyHandler) BeginRegistration(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	ceremony, err := h.usecase.BeginPasskeyRegistration(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	session.Set(passkeyRegistrationSession, string(ceremony.Session))
+	session.Save()
+
+	c.JSON(http.StatusOK, ceremony.Options)
+}
+
+func (h *PasskeyHandler) FinishRegistration(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	body := struct {
+		Name       string          `json:"name" binding:"required,max=255"`
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	ceremony := takeCeremony(session, passkeyRegistrationSession)
+	passkey, err := h.usecase.FinishPasskeyRegistration(userID, body.Name, ceremony, body.Credential)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, passkey)
+}
+
+func (h *PasskeyHandler) RenamePasskey(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	passkeyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(fmt.Errorf("%w: invalid passkey id", ErrValidation))
+		return
+	}
+
+	json := struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.RenamePasskey(userID, passkeyID, json.Name); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	passkeyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(fmt.Errorf("%w: invalid passkey id", ErrValidation))
+		return
+	}
+
+	if err := h.usecase.DeletePasskey(userID, passkeyID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PasskeyHandler) BeginLogin(c *gin.Context) {
+	ceremony, err := h.usecase.BeginPasskeyLogin()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(passkeyLoginSession, string(ceremony.Session))
+	session.Save()
+
+	c.JSON(http.StatusOK, ceremony.Options)
+}
+
+func (h *PasskeyHandler) FinishLogin(c *gin.Context) {
+	body := struct {
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	session := sessions.Default(c)
+	user, err := h.usecase.FinishPasskeyLogin(takeCeremony(session, passkeyLoginSession), body.Credential)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sessionManager.Start(c, session, user.ID); err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
+// BeginMFA starts a passkey assertion for a login waiting for its second
+// factor.
+func (h *PasskeyHandler) BeginMFA(c *gin.Context) {
+	session := sessions.Default(c)
+	userID, ok := pendingMFAUser(session)
+	if !ok {
+		c.Error(ErrUnauthorized)
+		return
+	}
+
+	ceremony, err := h.usecase.BeginPasskeyMFA(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	session.Set(passkeyMFASession, string(ceremony.Session))
+	session.Save()
+
+	c.JSON(http.StatusOK, ceremony.Options)
+}
+
+func (h *PasskeyHandler) FinishMFA(c *gin.Context) {
+	body := struct {
+		Credential json.RawMessage `json:"credential" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	session := sessions.Default(c)
+	userID, ok := pendingMFAUser(session)
+	if !ok {
+		c.Error(ErrUnauthorized)
+		return
+	}
+
+	if err := h.usecase.FinishPasskeyMFA(userID, takeCeremony(session, passkeyMFASession), body.Credential); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sessionManager.Start(c, session, userID); err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
+// takeCeremony removes the stored challenge so that each one can only be
+// answered once, whether or not the answer is valid.
+func takeCeremony(session sessions.Session, key string) []byte {
+	ceremony, _ := session.Get(key).(string)
+	session.Delete(key)
+	session.Save()
+	return []byte(ceremony)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	usecase in.PasswordResetUsecase
+}
+
+func NewPasswordHandler(usecase in.PasswordResetUsecase) *PasswordHandler {
+	return &PasswordHandler{
+		usecase: usecase,
+	}
+}
+
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	json := struct {
+		Email string `json:"email" binding:"required,email"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.RequestPasswordReset(json.Email, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	json := struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.ResetPassword(json.Token, json.Password, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+// RedirectURIPolicy decides which redirect URIs may be forwarded to a social
+// provider. URIs must match an exact entry or a pattern from the allow-list.
+type RedirectURIPolicy struct {
+	exact      map[string]bool
+	patterns   []*regexp.Regexp
+	defaultURI string
+}
+
+func NewRedirectURIPolicy() (*RedirectURIPolicy, error) {
+	policy := &RedirectURIPolicy{
+		exact:      map[string]bool{},
+		defaultURI: config.AppConfig.SocialDefaultRedirectURI,
+	}
+
+	for _, uri := range config.AppConfig.SocialRedirectURIs {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			policy.exact[uri] = true
+		}
+	}
+
+	for _, pattern := range config.AppConfig.SocialRedirectURIPatterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+
+		re, err := compileRedirectPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect URI pattern %q: %w", pattern, err)
+		}
+		policy.patterns = append(policy.patterns, re)
+	}
+
+	return policy, nil
+}
+
+// Resolve returns the redirect URI to use for a request, falling back to the
+// configured default when the client did not supply one.
+func (p *RedirectURIPolicy) Resolve(redirectUri string) (string, error) {
+	if redirectUri == "" {
+		redirectUri = p.defaultURI
+	}
+	if redirectUri == "" {
+		return "", fmt.Errorf("%w: redirect_uri is required", ErrRedirectURINotAllowed)
+	}
+
+	if !p.allowed(redirectUri) {
+		return "", fmt.Errorf("%w: %s", ErrRedirectURINotAllowed, redirectUri)
+	}
+
+	return redirectUri, nil
+}
+
+func (p *RedirectURIPolicy) allowed(redirectUri string) bool {
+	u, err := url.Parse(redirectUri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if u.User != nil || u.Fragment != "" {
+		return false
+	}
+
+	if p.exact[redirectUri] {
+		return true
+	}
+
+	for _, re := range p.patterns {
+		if re.MatchString(redirectUri) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileRedirectPattern turns a pattern such as "https://*.example.com/callback"
+// into an anchored regexp where "*" never crosses a "/", "?", "#" or "@".
+func compileRedirectPattern(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, `[^/?#@]*`) + "$")
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	gorillasessions "github.com/gorilla/sessions"
+)
+
+const (
+	// mfaChallengeTTL is how long a password or social login waits for the
+	// second factor before it has to be started again.
+	mfaChallengeTTL = 5 * time.Minute
+
+	// sessionActivityInterval limits how often the last activity of a session
+	// is saved, so that not every request rewrites the session.
+	sessionActivityInterval = time.Minute
+
+	defaultSessionIdleTimeout     = 30 * time.Minute
+	defaultSessionAbsoluteTimeout = 12 * time.Hour
+
+	rememberCookieName = "remember_me"
+)
+
+// SessionManager signs users in and out of the browser session. Every sign-in
+// moves the session to a new ID, signed-in sessions are kept in the user's
+// session index and end after the idle or absolute timeout, and a device that
+// opted into remember-me is signed back in by its remember-me cookie.
+type SessionManager struct {
+	usecase in.SessionUsecase
+}
+
+func NewSessionManager(usecase in.SessionUsecase) *SessionManager {
+	return &SessionManager{
+		usecase: usecase,
+	}
+}
+
+// CookieOptions are the options of the session cookie. Neither the cookie nor
+// the stored session outlives the absolute timeout.
+func (m *SessionManager) CookieOptions() sessions.Options {
+	return sessions.Options{
+		Path:     "/",
+		MaxAge:   int(m.absoluteTimeout().Seconds()),
+		Secure:   config.AppConfig.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Start signs the user in on a new session ID, records when they
+// authenticated, in Unix milliseconds, and adds the session to the user's
+// session index. A remember-me requested with the first factor is honored
+// once the pending second factor completes.
+func (m *SessionManager) Start(c *gin.Context, session sessions.Session, userID int64) error {
+	pendingUserID, pending := pendingMFAUser(session)
+	rememberMe, _ := session.Get("mfa_remember_me").(bool)
+
+	return m.start(c, session, userID, pending && pendingUserID == userID && rememberMe)
+}
+
+// SignIn completes a first-factor login. Users with two-factor
+// authentication are only put into the pending MFA state, and true is
+// returned so the caller can ask for their code.
+func (m *SessionManager) SignIn(c *gin.Context, session sessions.Session, user domain.User, rememberMe bool) (bool, error) {
+	if !user.MFAEnabled {
+		return false, m.start(c, session, user.ID, rememberMe)
+	}
+
+	if err := m.End(c, session); err != nil {
+		return false, err
+	}
+	if err := m.rotate(c, session); err != nil {
+		return false, err
+	}
+
+	session.Set("mfa_user_id", user.ID)
+	session.Set("mfa_started_at", time.Now().UnixMilli())
+	session.Set("mfa_remember_me", rememberMe)
+	return true, nil
+}
+
+// Reauthenticated records that the signed-in user has just confirmed their
+// identity again, which allows sensitive operations for the re-auth window.
+func (m *SessionManager) Reauthenticated(session sessions.Session) {
+	session.Set("auth_time", time.Now().UnixMilli())
+}
+
+// End signs the current session out, removes it from the user's session
+// index and forgets the remember-me cookie of the device.
+func (m *SessionManager) End(c *gin.Context, session sessions.Session) error {
+	userID, signedIn := session.Get("user_id").(int64)
+	sessionID, indexed := session.Get("session_id").(string)
+
+	signOut(session)
+	m.forgetDevice(c)
+
+	if !signedIn || !indexed {
+		return nil
+	}
+	return m.usecase.EndSession(userID, sessionID)
+}
+
+// Refresh runs on every request. It ends sessions past their idle or
+// absolute timeout and sessions revoked from another device, keeps the
+// session index up to date, and signs a remembered device back in.
+func (m *SessionManager) Refresh(c *gin.Context, session sessions.Session) error {
+	userID, ok := session.Get("user_id").(int64)
+	if !ok {
+		return m.resume(c, session)
+	}
+
+	if m.expired(session) {
+		// A remembered device keeps its index entry to resume it below.
+		if _, err := c.Cookie(rememberCookieName); err == nil {
+			signOut(session)
+		} else if err := m.End(c, session); err != nil {
+			return err
+		}
+		return m.resume(c, session)
+	}
+
+	now := time.Now().UnixMilli()
+
+	sessionID, ok := session.Get("session_id").(string)
+	if !ok {
+		// Sessions signed in before the session index existed are added to it.
+		userSession, err := m.usecase.StartSession(userID, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			return err
+		}
+		session.Set("session_id", userSession.ID)
+	} else if err := m.usecase.TouchSession(userID, sessionID, c.ClientIP()); err != nil {
+		if !errors.Is(err, domain.ErrSessionNotFound) {
+			return err
+		}
+
+		signOut(session)
+		m.forgetDevice(c)
+		return session.Save()
+	}
+
+	if _, ok := session.Get("started_at").(int64); !ok {
+		session.Set("started_at", now)
+	}
+
+	lastActiveAt, _ := session.Get("last_active_at").(int64)
+	if time.Since(time.UnixMilli(lastActiveAt)) >= sessionActivityInterval {
+		session.Set("last_active_at", now)
+	}
+
+	return session.Save()
+}
+
+func (m *SessionManager) start(c *gin.Context, session sessions.Session, userID int64, rememberMe bool) error {
+	if err := m.End(c, session); err != nil {
+		return err
+	}
+	if err := m.rotate(c, session); err != nil {
+		return err
+	}
+
+	userSession, err := m.usecase.StartSession(userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	session.Delete("mfa_user_id")
+	session.Delete("mfa_started_at")
+	session.Delete("mfa_remember_me")
+	session.Set("user_id", userID)
+	session.Set("auth_time", now)
+	session.Set("started_at", now)
+	session.Set("last_active_at", now)
+	session.Set("session_id", userSession.ID)
+
+	if !rememberMe {
+		return nil
+	}
+
+	token, rememberedUntil, err := m.usecase.RememberSession(userID, userSession.ID)
+	if err != nil {
+		return err
+	}
+
+	m.rememberDevice(c, token, rememberedUntil)
+	return nil
+}
+
+func (m *SessionManager) rememberDevice(c *gin.Context, token string, rememberedUntil time.Time) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  rememberedUntil,
+		MaxAge:   int(time.Until(rememberedUntil).Seconds()),
+		Secure:   config.AppConfig.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// resume signs a remembered device back in on a new session ID and replaces
+// its remember-me cookie. The session counts as authenticated when the device
+// originally signed in. A remember-me cookie whose session has expired or been
+// revoked is dropped.
+func (m *SessionManager) resume(c *gin.Context, session sessions.Session) error {
+	token, err := c.Cookie(rememberCookieName)
+	if err != nil {
+		return session.Save()
+	}
+
+	userSession, token, err := m.usecase.ResumeSession(token, c.ClientIP())
+	if err != nil {
+		if !errors.Is(err, domain.ErrSessionNotFound) {
+			return err
+		}
+		m.forgetDevice(c)
+		return session.Save()
+	}
+
+	// No token is returned to a request that raced another one resuming the
+	// same device; the cookie that one sets is kept.
+	if token != "" && userSession.RememberedUntil != nil {
+		m.rememberDevice(c, token, *userSession.RememberedUntil)
+	}
+
+	if err := m.rotate(c, session); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	session.Set("user_id", userSession.UserID)
+	session.Set("auth_time", userSession.CreatedAt.UnixMilli())
+	session.Set("started_at", now)
+	session.Set("last_active_at", now)
+	session.Set("session_id", userSession.ID)
+	return session.Save()
+}
+
+func (m *SessionManager) expired(session sessions.Session) bool {
+	if startedAt, ok := session.Get("started_at").(int64); ok && time.Since(time.UnixMilli(startedAt)) > m.absoluteTimeout() {
+		return true
+	}
+	if lastActiveAt, ok := session.Get("last_active_at").(int64); ok && time.Since(time.UnixMilli(lastActiveAt)) > m.idleTimeout() {
+		return true
+	}
+	return false
+}
+
+func (m *SessionManager) idleTimeout() time.Duration {
+	if config.AppConfig.SessionIdleTimeout > 0 {
+		return config.AppConfig.SessionIdleTimeout
+	}
+	return defaultSessionIdleTimeout
+}
+
+func (m *SessionManager) absoluteTimeout() time.Duration {
+	if config.AppConfig.SessionAbsoluteTimeout > 0 {
+		return config.AppConfig.SessionAbsoluteTimeout
+	}
+	return defaultSessionAbsoluteTimeout
+}
+
+// rotate moves the session to a new ID, keeping its values, so that an ID
+// planted in the browser before a sign-in is worthless afterward.
+func (m *SessionManager) rotate(c *gin.Context, session sessions.Session) error {
+	store, ok := session.(interface {
+		Session() *gorillasessions.Session
+	})
+	if !ok {
+		return errors.New("the session does not support rotating its ID")
+	}
+	s := store.Session()
+
+	if !s.IsNew {
+		// Delete the old session from the store. Its cookie is replaced when
+		// the new session is saved, so the expired one is not sent.
+		old := *s
+		options := *s.Options
+		options.MaxAge = -1
+		old.Options = &options
+
+		if err := s.Store().Save(c.Request, discardHeaders{}, &old); err != nil {
+			return err
+		}
+	}
+
+	s.ID = ""
+	s.IsNew = true
+	return nil
+}
+
+// forgetDevice drops the remember-me cookie, if the device has one.
+func (m *SessionManager) forgetDevice(c *gin.Context) {
+	if _, err := c.Cookie(rememberCookieName); err != nil {
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     rememberCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   config.AppConfig.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signOut removes the signed-in user from the session.
+func signOut(session sessions.Session) {
+	session.Delete("user_id")
+	session.Delete("auth_time")
+	session.Delete("started_at")
+	session.Delete("last_active_at")
+	session.Delete("session_id")
+}
+
+// pendingMFAUser returns the user waiting for their second factor, if the
+// challenge has not expired.
+func pendingMFAUser(session sessions.Session) (int64, bool) {
+	userID, ok := session.Get("mfa_user_id").(int64)
+	if !ok {
+		return 0, false
+	}
+
+	startedAt, ok := session.Get("mfa_started_at").(int64)
+	if !ok || time.Since(time.UnixMilli(startedAt)) > mfaChallengeTTL {
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// sessionAuthTime returns when the session's user authenticated, or the zero
+// time for sessions started before it was recorded.
+func sessionAuthTime(session sessions.Session) time.Time {
+	authTime, ok := session.Get("auth_time").(int64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(authTime)
+}
+
+// discardHeaders is a response writer for store operations whose cookies must
+// not reach the client.
+type discardHeaders struct{}
+
+func (discardHeaders) Header() http.Header {
+	return http.Header{}
+}
+
+func (discardHeaders) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (discardHeaders) WriteHeader(int) {}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	usecase        in.SessionUsecase
+	sessionManager *SessionManager
+}
+
+func NewSessionHandler(usecase in.SessionUsecase, sessionManager *SessionManager) *SessionHandler {
+	return &SessionHandler{
+		usecase:        usecase,
+		sessionManager: sessionManager,
+	}
+}
+
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+	currentID, _ := session.Get("session_id").(string)
+
+	userSessions, err := h.usecase.ListSessions(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	type sessionResponse struct {
+		domain.UserSession
+		Current bool `json:"current"`
+	}
+
+	response := make([]sessionResponse, 0, len(userSessions))
+	for _, userSession := range userSessions {
+		response = append(response, sessionResponse{
+			UserSession: userSession,
+			Current:     userSession.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession signs out one of the user's sessions. Revoking the current
+// session is the same as logging out.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+	sessionID := c.Param("id")
+
+	if err := h.usecase.RevokeSession(userID, sessionID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if currentID, _ := session.Get("session_id").(string); currentID == sessionID {
+		if err := h.sessionManager.End(c, session); err != nil {
+			c.Error(err)
+			return
+		}
+		session.Save()
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs out every session of the user except the current
+// one.
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+	currentID, _ := session.Get("session_id").(string)
+
+	if err := h.usecase.RevokeOtherSessions(userID, currentID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPostLoginUrl   = "/template/user/social-links"
+	defaultLinkConfirmUrl = "/auth/link/confirm"
+	defaultSocialErrorUrl = "/template/login"
+	mfaChallengeUrl       = "/template/login/mfa"
+)
+
+// SocialRedirectHandler runs the social login entirely through browser
+// redirects, for clients that cannot post the callback parameters themselves.
+// Failures redirect back to the client as well, see fail.
+type SocialRedirectHandler struct {
+	usecase        in.UserUsecase
+	sessionManager *SessionManager
+	states         *StateManager
+	redirects      *RedirectURIPolicy
+	postLoginUrl   string
+	linkConfirmUrl string
+	errorUrl       string
+}
+
+func NewSocialRedirectHandler(
+	usecase in.UserUsecase,
+	sessionManager *SessionManager,
+	states *StateManager,
+	redirects *RedirectURIPolicy,
+) *SocialRedirectHandler {
+	h := &SocialRedirectHandler{
+		usecase:        usecase,
+		sessionManager: sessionManager,
+		states:         states,
+		redirects:      redirects,
+		postLoginUrl:   config.AppConfig.SocialPostLoginUrl,
+		linkConfirmUrl: config.AppConfig.SocialLinkConfirmUrl,
+		errorUrl:       config.AppConfig.SocialErrorUrl,
+	}
+
+	if h.postLoginUrl == "" {
+		h.postLoginUrl = defaultPostLoginUrl
+	}
+	if h.linkConfirmUrl == "" {
+		h.linkConfirmUrl = defaultLinkConfirmUrl
+	}
+	if h.errorUrl == "" {
+		h.errorUrl = defaultSocialErrorUrl
+	}
+
+	return h
+}
+
+func (h *SocialRedirectHandler) Login(c *gin.Context) {
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	h.redirectToProvider(c, provider, AuthFlowLogin)
+}
+
+func (h *SocialRedirectHandler) Link(c *gin.Context) {
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	if _, err := h.pendingLinkToken(sessions.Default(c)); err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	h.redirectToProvider(c, provider, AuthFlowLink)
+}
+
+func (h *SocialRedirectHandler) Callback(c *gin.Context) {
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		h.fail(c, fmt.Errorf("%w: provider returned %s", ErrValidation, providerErr))
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		h.fail(c, fmt.Errorf("%w: code and state are required", ErrValidation))
+		return
+	}
+
+	redirectUri, err := h.callbackUrl(provider)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	session := sessions.Default(c)
+	params, flow, err := h.states.ConsumeAny(session, state, provider.ProviderName(), redirectUri, AuthFlowLogin, AuthFlowLink)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	if flow == AuthFlowLink {
+		h.completeLink(c, session, provider, code, redirectUri, params)
+		return
+	}
+
+	h.completeLogin(c, session, provider, code, redirectUri, params)
+}
+
+func (h *SocialRedirectHandler) LinkConfirm(c *gin.Context) {
+	linkToken, err := h.pendingLinkToken(sessions.Default(c))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	pendingLink, err := h.usecase.ValidateLinkToken(linkToken)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.HTML(http.StatusOK, "link_confirm.tmpl", gin.H{
+		"title":          "Link Social Account",
+		"showNav":        false,
+		"socialAccounts": pendingLink.LinkedSocialAccounts,
+	})
+}
+
+func (h *SocialRedirectHandler) completeLogin(
+	c *gin.Context,
+	session sessions.Session,
+	provider socialproviders.SocialProvider,
+	code string,
+	redirectUri string,
+	params socialproviders.AuthParams,
+) {
+	result, err := h.usecase.AuthenticateSocialUser(provider, code, redirectUri, params)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	if result.Status == in.AuthLinkRequired {
+		session.Set("link_token", result.LinkToken)
+		session.Save()
+		c.Redirect(http.StatusFound, h.linkConfirmUrl)
+		return
+	}
+
+	mfaRequired, err := h.sessionManager.SignIn(c, session, result.User, false)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	session.Save()
+
+	h.redirectAfterSignIn(c, mfaRequired)
+}
+
+func (h *SocialRedirectHandler) completeLink(
+	c *gin.Context,
+	session sessions.Session,
+	provider socialproviders.SocialProvider,
+	code string,
+	redirectUri string,
+	params socialproviders.AuthParams,
+) {
+	linkToken, err := h.pendingLinkToken(session)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	user, err := h.usecase.LinkUserWithSocialAccount(provider, code, linkToken, redirectUri, params)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	session.Delete("link_token")
+	mfaRequired, err := h.sessionManager.SignIn(c, session, user, false)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	session.Save()
+
+	h.redirectAfterSignIn(c, mfaRequired)
+}
+
+func (h *SocialRedirectHandler) redirectAfterSignIn(c *gin.Context, mfaRequired bool) {
+	if mfaRequired {
+		c.Redirect(http.StatusFound, mfaChallengeUrl)
+		return
+	}
+	c.Redirect(http.StatusFound, h.postLoginUrl)
+}
+
+func (h *SocialRedirectHandler) redirectToProvider(c *gin.Context, provider socialproviders.SocialProvider, flow AuthFlow) {
+	redirectUri, err := h.callbackUrl(provider)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	params, err := h.states.Issue(sessions.Default(c), provider.ProviderName(), flow, redirectUri)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	url, err := h.usecase.SocialAuthUrl(provider, redirectUri, params)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+func (h *SocialRedirectHandler) callbackUrl(provider socialproviders.SocialProvider) (string, error) {
+	callbackUrl := fmt.Sprintf(
+		"%s/auth/social/%s/callback",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		provider.ProviderName(),
+	)
+	return h.redirects.Resolve(callbackUrl)
+}
+
+func (h *SocialRedirectHandler) pendingLinkToken(session sessions.Session) (string, error) {
+	linkToken, ok := session.Get("link_token").(string)
+	if !ok || linkToken == "" {
+		return "", fmt.Errorf("%w: no pending social account link", domain.ErrInvalidLinkToken)
+	}
+	return linkToken, nil
+}
+
+// fail redirects the browser to the error URL of the client with the error
+// code in the "error" query parameter, rather than leaving it on a JSON error
+// body in the middle of the redirects.
+func (h *SocialRedirectHandler) fail(c *gin.Context, err error) {
+	code := "INTERNAL_ERROR"
+	if errorCode, ok := LookupErrorCode(err); ok {
+		code = errorCode.Code
+	} else {
+		log.Printf("social redirect login failed: %v", err)
+	}
+
+	errorUrl, parseErr := url.Parse(h.errorUrl)
+	if parseErr != nil {
+		errorUrl = &url.URL{Path: defaultSocialErrorUrl}
+	}
+
+	query := errorUrl.Query()
+	query.Set("error", code)
+	errorUrl.RawQuery = query.Encode()
+
+	c.Redirect(http.StatusFound, errorUrl.String())
+}
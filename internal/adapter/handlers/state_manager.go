@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
+	"github.com/gin-contrib/sessions"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionKeyOAuthStates = "oauth_states"
+	maxPendingStates      = 10
+	defaultStateTTL       = 10 * time.Minute
+)
+
+type AuthFlow string
+
+const (
+	AuthFlowLogin   AuthFlow = "login"   // Sign in or sign up with a social account
+	AuthFlowLink    AuthFlow = "link"    // Link a social account to an existing user with a link token
+	AuthFlowConnect AuthFlow = "connect" // Connect a social account to the signed-in user
+	AuthFlowReauth  AuthFlow = "reauth"  // Confirm the identity of the signed-in user with a linked social account
+)
+
+type pendingState struct {
+	Provider     string   `json:"provider"`
+	Flow         AuthFlow `json:"flow"`
+	RedirectURI  string   `json:"redirect_uri"`
+	CodeVerifier string   `json:"code_verifier"`
+	Nonce        string   `json:"nonce"`
+	ExpiresAt    int64    `json:"expires_at"`
+}
+
+// StateManager issues OAuth2 state values bound to the current session, the
+// provider, the flow and the redirect URI, and consumes each of them once.
+type StateManager struct {
+	ttl time.Duration
+}
+
+func NewStateManager() *StateManager {
+	ttl := config.AppConfig.OAuthStateTTL
+	if ttl <= 0 {
+		ttl = defaultStateTTL
+	}
+
+	return &StateManager{
+		ttl: ttl,
+	}
+}
+
+func (m *StateManager) Issue(session sessions.Session, provider string, flow AuthFlow, redirectUri string) (socialproviders.AuthParams, error) {
+	state, err := randomHex(32)
+	if err != nil {
+		return socialproviders.AuthParams{}, fmt.Errorf("failed to generate random state: %w", err)
+	}
+
+	nonce, err := randomHex(32)
+	if err != nil {
+		return socialproviders.AuthParams{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	pending := m.load(session)
+	m.prune(pending)
+
+	pending[state] = pendingState{
+		Provider:     provider,
+		Flow:         flow,
+		RedirectURI:  redirectUri,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(m.ttl).Unix(),
+	}
+
+	if err := m.save(session, pending); err != nil {
+		return socialproviders.AuthParams{}, err
+	}
+
+	return socialproviders.AuthParams{
+		State:        state,
+		CodeVerifier: pending[state].CodeVerifier,
+		Nonce:        nonce,
+	}, nil
+}
+
+// Consume removes the state from the session and returns its parameters if it
+// was issued for the same provider, flow and redirect URI and has not expired.
+func (m *StateManager) Consume(session sessions.Session, state, provider string, flow AuthFlow, redirectUri string) (socialproviders.AuthParams, error) {
+	params, _, err := m.ConsumeAny(session, state, provider, redirectUri, flow)
+	return params, err
+}
+
+// ConsumeAny is like Consume but accepts a state issued for any of the given
+// flows and reports which one it was.
+func (m *StateManager) ConsumeAny(
+	session sessions.Session,
+	state string,
+	provider string,
+	redirectUri string,
+	flows ...AuthFlow,
+) (socialproviders.AuthParams, AuthFlow, error) {
+	pending := m.load(session)
+	entry, ok := pending[state]
+	if !ok {
+		return socialproviders.AuthParams{}, "", fmt.Errorf("%w: unknown or already used state", ErrInvalidState)
+	}
+
+	delete(pending, state)
+	if err := m.save(session, pending); err != nil {
+		return socialproviders.AuthParams{}, "", err
+	}
+
+	if time.Now().Unix() > entry.ExpiresAt {
+		return socialproviders.AuthParams{}, "", fmt.Errorf("%w: state has expired", ErrInvalidState)
+	}
+
+	if entry.Provider != provider || !slices.Contains(flows, entry.Flow) || entry.RedirectURI != redirectUri {
+		return socialproviders.AuthParams{}, "", fmt.Errorf("%w: state was issued for a different request", ErrInvalidState)
+	}
+
+	return socialproviders.AuthParams{
+		State:        state,
+		CodeVerifier: entry.CodeVerifier,
+		Nonce:        entry.Nonce,
+	}, entry.Flow, nil
+}
+
+func (m *StateManager) load(session sessions.Session) map[string]pendingState {
+	pending := map[string]pendingState{}
+
+	raw, ok := session.Get(sessionKeyOAuthStates).(string)
+	if !ok {
+		return pending
+	}
+
+	if err := json.Unmarshal([]byte(raw), &pending); err != nil {
+		return map[string]pendingState{}
+	}
+	return pending
+}
+
+func (m *StateManager) save(session sessions.Session, pending map[string]pendingState) error {
+	raw, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to encode oauth states: %w", err)
+	}
+
+	session.Set(sessionKeyOAuthStates, string(raw))
+	if err := session.Save(); err != nil {
+		return fmt.Errorf("failed to save oauth states: %w", err)
+	}
+	return nil
+}
+
+// prune drops expired entries and, if still too many are pending, the ones
+// closest to expiry, so the session cannot grow without bound.
+func (m *StateManager) prune(pending map[string]pendingState) {
+	now := time.Now().Unix()
+	for state, entry := range pending {
+		if now > entry.ExpiresAt {
+			delete(pending, state)
+		}
+	}
+
+	for len(pending) >= maxPendingStates {
+		var oldest string
+		for state, entry := range pending {
+			if oldest == "" || entry.ExpiresAt < pending[oldest].ExpiresAt {
+				oldest = state
+			}
+		}
+		delete(pending, oldest)
+	}
+}
+
+func randomHex(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
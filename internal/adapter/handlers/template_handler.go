@@ -20,6 +20,41 @@ func (h *TemplateHandler) Login(c *gin.Context) {
 	})
 }
 
+func (h *TemplateHandler) LoginMFA(c *gin.Context) {
+	c.HTML(http.StatusOK, "mfa.tmpl", gin.H{
+		"title":   "Two-Factor Authentication",
+		"showNav": false,
+	})
+}
+
+func (h *TemplateHandler) MagicLink(c *gin.Context) {
+	c.HTML(http.StatusOK, "magic_link.tmpl", gin.H{
+		"title":   "Sign In",
+		"showNav": false,
+	})
+}
+
+func (h *TemplateHandler) VerifyEmail(c *gin.Context) {
+	c.HTML(http.StatusOK, "verify_email.tmpl", gin.H{
+		"title":   "Verify Email",
+		"showNav": false,
+	})
+}
+
+func (h *TemplateHandler) ResetPassword(c *gin.Context) {
+	c.HTML(http.StatusOK, "reset_password.tmpl", gin.H{
+		"title":   "Reset Password",
+		"showNav": false,
+	})
+}
+
+func (h *TemplateHandler) UnlockAccount(c *gin.Context) {
+	c.HTML(http.StatusOK, "account_unlock.tmpl", gin.H{
+		"title":   "Unlock Account",
+		"showNav": false,
+	})
+}
+
 func (h *TemplateHandler) SocialLinks(c *gin.Context) {
 	c.HTML(http.StatusOK, "social_links.tmpl", gin.H{
 		"title":   "User Social Links",
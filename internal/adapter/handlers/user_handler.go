@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"time"
 
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/config"
@@ -17,20 +16,32 @@ import (
 )
 
 var (
-	ErrValidation        = errors.New("Validation error")
-	ErrUnauthorized      = errors.New("Requires authentication.")
-	ErrInvalidState      = errors.New("Invalid state.")
-	ErrMissingFile       = errors.New("Missing file.")
-	ErrInvalidFileFormat = errors.New("Invalid file format")
+	ErrValidation            = errors.New("Validation error")
+	ErrUnauthorized          = errors.New("Requires authentication.")
+	ErrInvalidState          = errors.New("Invalid state.")
+	ErrRedirectURINotAllowed = errors.New("Redirect URI is not allowed.")
+	ErrMissingFile           = errors.New("Missing file.")
+	ErrInvalidFileFormat     = errors.New("Invalid file format")
 )
 
 type UserHandler struct {
-	usecase in.UserUsecase
+	usecase        in.UserUsecase
+	sessionManager *SessionManager
+	states         *StateManager
+	redirects      *RedirectURIPolicy
 }
 
-func NewUserHandler(usecase in.UserUsecase) *UserHandler {
+func NewUserHandler(
+	usecase in.UserUsecase,
+	sessionManager *SessionManager,
+	states *StateManager,
+	redirects *RedirectURIPolicy,
+) *UserHandler {
 	return &UserHandler{
-		usecase: usecase,
+		usecase:        usecase,
+		sessionManager: sessionManager,
+		states:         states,
+		redirects:      redirects,
 	}
 }
 
@@ -66,8 +77,9 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 func (h *UserHandler) LoginWithEmail(c *gin.Context) {
 	json := struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required"`
+		Email      string `json:"email" binding:"required"`
+		Password   string `json:"password" binding:"required"`
+		RememberMe bool   `json:"remember_me"` // Keep this device signed in after the session expires
 	}{}
 
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -75,16 +87,25 @@ func (h *UserHandler) LoginWithEmail(c *gin.Context) {
 		return
 	}
 
-	user, err := h.usecase.AuthenticateUser(json.Email, json.Password)
+	user, err := h.usecase.AuthenticateUser(json.Email, json.Password, c.ClientIP())
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
 	session := sessions.Default(c)
-	session.Set("user_id", user.ID)
+	mfaRequired, err := h.sessionManager.SignIn(c, session, user, json.RememberMe)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	session.Save()
 
+	if mfaRequired {
+		c.JSON(http.StatusOK, gin.H{"code": in.AuthMFARequired})
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -107,6 +128,127 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// GetLoginMethods lists the ways the user can sign in, so that clients can
+// keep the user from removing the last one.
+func (h *UserHandler) GetLoginMethods(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	methods, err := h.usecase.GetLoginMethods(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, methods)
+}
+
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Name      *string    `json:"name" binding:"omitnil,min=1,max=255"`
+		Email     *string    `json:"email" binding:"omitnil,email,max=255"`
+		UpdatedAt *time.Time `json:"updated_at" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	user, err := h.usecase.UpdateUser(userID, in.UpdateUserRequest{
+		Name:      json.Name,
+		Email:     json.Email,
+		UpdatedAt: *json.UpdatedAt,
+		AuthTime:  sessionAuthTime(session),
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Password string `json:"password"` // Omitted by social-only users
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	purgeAt, err := h.usecase.DeleteUser(userID, json.Password, sessionAuthTime(session), c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.sessionManager.End(c, session); err != nil {
+		c.Error(err)
+		return
+	}
+	session.Clear()
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{
+		"purge_at": purgeAt,
+	})
+}
+
+// Reauthenticate confirms the password of the signed-in user so that the
+// session may perform sensitive operations again.
+func (h *UserHandler) Reauthenticate(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		Password string `json:"password" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.Reauthenticate(userID, json.Password, c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.sessionManager.Reauthenticated(session)
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *UserHandler) Logout(c *gin.Context) {
 	session := sessions.Default(c)
 	v := session.Get("user_id")
@@ -116,14 +258,16 @@ func (h *UserHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	session.Delete("user_id")
+	if err := h.sessionManager.End(c, session); err != nil {
+		c.Error(err)
+		return
+	}
 	session.Save()
 	c.Status(http.StatusNoContent)
 }
 
 func (h *UserHandler) SocialAuthURL(c *gin.Context) {
 	providerName := c.Param("provider")
-	redirectUri := c.Query("redirect_uri")
 
 	provider, err := socialproviders.NewSocialProvider(providerName)
 	if err != nil {
@@ -131,21 +275,23 @@ func (h *UserHandler) SocialAuthURL(c *gin.Context) {
 		return
 	}
 
-	state, err := h.generateState()
+	redirectUri, err := h.redirects.Resolve(c.Query("redirect_uri"))
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	url, err := h.usecase.SocialAuthUrl(provider, state, redirectUri)
+	params, err := h.states.Issue(sessions.Default(c), provider.ProviderName(), AuthFlowLogin, redirectUri)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	session := sessions.Default(c)
-	session.Set("state", state)
-	session.Save()
+	url, err := h.usecase.SocialAuthUrl(provider, redirectUri, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"auth_url": url,
@@ -157,7 +303,7 @@ func (h *UserHandler) SocialAuthCallback(c *gin.Context) {
 		Provider    string `json:"provider" binding:"required"`
 		Code        string `json:"code" binding:"required"`
 		State       string `json:"state" binding:"required"`
-		RedirectURI string `json:"redirect_uri" binding:"required"`
+		RedirectURI string `json:"redirect_uri"`
 	}{}
 
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -171,7 +317,20 @@ func (h *UserHandler) SocialAuthCallback(c *gin.Context) {
 		return
 	}
 
-	result, err := h.usecase.AuthenticateSocialUser(provider, json.Code, json.RedirectURI)
+	session := sessions.Default(c)
+	redirectUri, err := h.redirects.Resolve(json.RedirectURI)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, err := h.states.Consume(session, json.State, provider.ProviderName(), AuthFlowLogin, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := h.usecase.AuthenticateSocialUser(provider, json.Code, redirectUri, params)
 	if err != nil {
 		c.Error(err)
 		return
@@ -185,16 +344,23 @@ func (h *UserHandler) SocialAuthCallback(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	session.Set("user_id", result.User.ID)
+	mfaRequired, err := h.sessionManager.SignIn(c, session, result.User, false)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	session.Save()
 
+	if mfaRequired {
+		c.JSON(http.StatusOK, gin.H{"code": in.AuthMFARequired})
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
 func (h *UserHandler) SocialAuthUrlForLinkingExistingUser(c *gin.Context) {
 	providerName := c.Param("provider")
-	redirectUri := c.Query("redirect_uri")
 	linkToken := c.Query("link_token")
 
 	provider, err := socialproviders.NewSocialProvider(providerName)
@@ -203,15 +369,28 @@ func (h *UserHandler) SocialAuthUrlForLinkingExistingUser(c *gin.Context) {
 		return
 	}
 
+	redirectUri, err := h.redirects.Resolve(c.Query("redirect_uri"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	_, err = h.usecase.ValidateLinkToken(linkToken)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	url, err := h.usecase.SocialAuthUrl(provider, linkToken, redirectUri)
+	params, err := h.states.Issue(sessions.Default(c), provider.ProviderName(), AuthFlowLink, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	url, err := h.usecase.SocialAuthUrl(provider, redirectUri, params)
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	c.IndentedJSON(http.StatusOK, gin.H{
@@ -223,8 +402,9 @@ func (h *UserHandler) LinkUserWithSocialAccount(c *gin.Context) {
 	json := struct {
 		Provider    string `json:"provider" binding:"required"`
 		Code        string `json:"code" binding:"required"`
+		State       string `json:"state" binding:"required"`
 		LinkToken   string `json:"link_token" binding:"required"`
-		RedirectURI string `json:"redirect_uri" binding:"required"`
+		RedirectURI string `json:"redirect_uri"`
 	}{}
 
 	if err := c.ShouldBindJSON(&json); err != nil {
@@ -238,14 +418,157 @@ func (h *UserHandler) LinkUserWithSocialAccount(c *gin.Context) {
 		return
 	}
 
-	user, err := h.usecase.LinkUserWithSocialAccount(provider, json.Code, json.LinkToken, json.RedirectURI)
+	session := sessions.Default(c)
+	redirectUri, err := h.redirects.Resolve(json.RedirectURI)
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	params, err := h.states.Consume(session, json.State, provider.ProviderName(), AuthFlowLink, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.usecase.LinkUserWithSocialAccount(provider, json.Code, json.LinkToken, redirectUri, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	mfaRequired, err := h.sessionManager.SignIn(c, session, user, false)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	if mfaRequired {
+		c.JSON(http.StatusOK, gin.H{"code": in.AuthMFARequired})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) SocialAuthUrlForConnectingAccount(c *gin.Context) {
+	session := sessions.Default(c)
+	if session.Get("user_id") == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	redirectUri, err := h.redirects.Resolve(c.Query("redirect_uri"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, err := h.states.Issue(session, provider.ProviderName(), AuthFlowConnect, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	url, err := h.usecase.SocialAuthUrl(provider, redirectUri, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"auth_url": url,
+	})
+}
+
+func (h *UserHandler) SocialAuthUrlForReauth(c *gin.Context) {
+	session := sessions.Default(c)
+	if session.Get("user_id") == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	redirectUri, err := h.redirects.Resolve(c.Query("redirect_uri"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, err := h.states.Issue(session, provider.ProviderName(), AuthFlowReauth, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	url, err := h.usecase.SocialAuthUrl(provider, redirectUri, params)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"auth_url": url,
+	})
+}
+
+// ReauthenticateSocial confirms the identity of the signed-in user with a
+// fresh login to one of their linked social accounts.
+func (h *UserHandler) ReauthenticateSocial(c *gin.Context) {
 	session := sessions.Default(c)
-	session.Set("user_id", user.ID)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	provider, err := socialproviders.NewSocialProvider(c.Param("provider"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	json := struct {
+		Code        string `json:"code" binding:"required"`
+		State       string `json:"state" binding:"required"`
+		RedirectURI string `json:"redirect_uri"`
+	}{}
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	redirectUri, err := h.redirects.Resolve(json.RedirectURI)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, err := h.states.Consume(session, json.State, provider.ProviderName(), AuthFlowReauth, redirectUri)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.usecase.ReauthenticateSocialUser(userID, provider, json.Code, redirectUri, params); err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.sessionManager.Reauthenticated(session)
 	session.Save()
 
 	c.Status(http.StatusNoContent)
@@ -269,20 +592,26 @@ func (h *UserHandler) LinkSocialAccount(c *gin.Context) {
 	json := struct {
 		Code        string `json:"code" binding:"required"`
 		State       string `json:"state" binding:"required"`
-		RedirectURI string `json:"redirect_uri" binding:"required"`
+		RedirectURI string `json:"redirect_uri"`
 	}{}
 	if err := c.ShouldBindJSON(&json); err != nil {
 		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
 		return
 	}
 
-	v = session.Get("state")
-	if v == nil || v.(string) != json.State {
-		c.Error(ErrInvalidState)
+	redirectUri, err := h.redirects.Resolve(json.RedirectURI)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, err := h.states.Consume(session, json.State, provider.ProviderName(), AuthFlowConnect, redirectUri)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := h.usecase.LinkSocialAccount(userID, provider, json.Code, json.RedirectURI); err != nil {
+	if err := h.usecase.LinkSocialAccount(userID, provider, json.Code, redirectUri, params); err != nil {
 		c.Error(err)
 		return
 	}
@@ -313,6 +642,40 @@ func (h *UserHandler) UnlinkSocialAccount(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	session := sessions.Default(c)
+	v := session.Get("user_id")
+	if v == nil {
+		c.Error(ErrUnauthorized)
+		return
+	}
+	userID := v.(int64)
+
+	json := struct {
+		CurrentPassword string `json:"current_password"` // Omitted by social-only users setting their first password
+		Password        string `json:"password" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindJSON(&json); err != nil {
+		c.Error(fmt.Errorf("%w: %v", ErrValidation, err.Error()))
+		return
+	}
+
+	if err := h.usecase.ChangePassword(userID, json.CurrentPassword, json.Password, sessionAuthTime(session), c.ClientIP()); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Changing the password revokes every session, so sign this one in again.
+	if err := h.sessionManager.Start(c, session, userID); err != nil {
+		c.Error(err)
+		return
+	}
+	session.Save()
+
+	c.Status(http.StatusNoContent)
+}
+
 func (h *UserHandler) UpdateUserAvatar(c *gin.Context) {
 	session := sessions.Default(c)
 	v := session.Get("user_id")
@@ -353,12 +716,3 @@ func (h *UserHandler) UpdateUserAvatar(c *gin.Context) {
 		"avatar_url": avatarUrl,
 	})
 }
-
-func (h *UserHandler) generateState() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to generate random state: %w", err)
-	}
-
-	return hex.EncodeToString(bytes), nil
-}
@@ -0,0 +1,95 @@
+package hashers
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+const defaultAlgorithm = "argon2id"
+
+// Algorithm hashes passwords with one algorithm and recognizes its encoded
+// hashes.
+type Algorithm interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+	Identifies(encoded string) bool
+}
+
+// PasswordHasher hashes new passwords with the configured algorithm and
+// verifies hashes made with any supported algorithm, so existing users keep
+// signing in after the algorithm or its parameters change.
+type PasswordHasher struct {
+	current    Algorithm
+	algorithms []Algorithm
+}
+
+func NewPasswordHasher() (*PasswordHasher, error) {
+	bcryptHasher := NewBcryptHasher(config.AppConfig.BcryptCost)
+	argon2idHasher := NewArgon2idHasher(
+		config.AppConfig.Argon2Memory,
+		config.AppConfig.Argon2Iterations,
+		config.AppConfig.Argon2Parallelism,
+	)
+
+	algorithm := config.AppConfig.PasswordHashAlgorithm
+	if algorithm == "" {
+		algorithm = defaultAlgorithm
+	}
+
+	var current Algorithm
+	switch algorithm {
+	case "bcrypt":
+		current = bcryptHasher
+	case "argon2id":
+		current = argon2idHasher
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", algorithm)
+	}
+
+	return &PasswordHasher{
+		current:    current,
+		algorithms: []Algorithm{argon2idHasher, bcryptHasher},
+	}, nil
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify reports whether the password matches the encoded hash. A hash in an
+// unrecognized or malformed format never matches, so that the user fails to
+// sign in with invalid credentials instead of a server error; it is logged for
+// an operator to look into.
+func (h *PasswordHasher) Verify(password, encoded string) (bool, error) {
+	algorithm := h.algorithmOf(encoded)
+	if algorithm == nil {
+		log.Printf("unrecognized password hash format")
+		return false, nil
+	}
+
+	ok, err := algorithm.Verify(password, encoded)
+	if err != nil {
+		log.Printf("malformed password hash: %v", err)
+		return false, nil
+	}
+	return ok, nil
+}
+
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	if !h.current.Identifies(encoded) {
+		return true
+	}
+	return h.current.NeedsRehash(encoded)
+}
+
+func (h *PasswordHasher) algorithmOf(encoded string) Algorithm {
+	for _, algorithm := range h.algorithms {
+		if algorithm.Identifies(encoded) {
+			return algorithm
+		}
+	}
+	return nil
+}
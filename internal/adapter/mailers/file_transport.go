@@ -0,0 +1,52 @@
+package mailers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileTransport stores each mail as an .eml file in a maildir layout, so local
+// development can inspect outgoing mail without an SMTP server.
+type FileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("mail file directory is not configured")
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+
+	return &FileTransport{
+		dir: dir,
+	}, nil
+}
+
+func (t *FileTransport) Deliver(msg Message) error {
+	body, err := buildMIME(msg)
+	if err != nil {
+		return fmt.Errorf("failed to build mail: %w", err)
+	}
+
+	// Write into tmp first and rename into new so readers never see a partial file.
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), uuid.New().String())
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, body, 0o644); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(t.dir, "new", name)); err != nil {
+		return fmt.Errorf("failed to deliver mail file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package mailers
+
+import (
+	"log"
+)
+
+// LogTransport records outgoing mail in the application log instead of
+// delivering it. Only the envelope is logged, never the body, which carries
+// the tokens of verification, reset, unlock and sign-in links.
+type LogTransport struct {
+}
+
+func NewLogTransport() *LogTransport {
+	return &LogTransport{}
+}
+
+func (t *LogTransport) Deliver(msg Message) error {
+	log.Printf("mail to=%q subject=%q template=%q", msg.To, msg.Subject, msg.Template)
+	return nil
+}
@@ -0,0 +1,111 @@
+package mailers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	texttemplate "text/template"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+const defaultTemplateDir = "web/templates/emails"
+
+// Message is a fully rendered mail ready to be handed to a transport.
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	Template string // Name of the templates the bodies were rendered from
+	TextBody string
+	HTMLBody string
+}
+
+type Transport interface {
+	Deliver(msg Message) error
+}
+
+// TemplateMailer renders the html and text templates of a mail and hands the
+// result to the configured transport.
+type TemplateMailer struct {
+	from      string
+	transport Transport
+	html      *htmltemplate.Template
+	text      *texttemplate.Template
+}
+
+func NewMailer(transport Transport) (*TemplateMailer, error) {
+	dir := config.AppConfig.MailTemplateDir
+	if dir == "" {
+		dir = defaultTemplateDir
+	}
+
+	return NewTemplateMailer(dir, config.AppConfig.MailFrom, transport)
+}
+
+func NewTemplateMailer(templateDir, from string, transport Transport) (*TemplateMailer, error) {
+	html, err := htmltemplate.ParseGlob(filepath.Join(templateDir, "*.html.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html mail templates: %w", err)
+	}
+
+	text, err := texttemplate.ParseGlob(filepath.Join(templateDir, "*.txt.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text mail templates: %w", err)
+	}
+
+	return &TemplateMailer{
+		from:      from,
+		transport: transport,
+		html:      html,
+		text:      text,
+	}, nil
+}
+
+func (m *TemplateMailer) Send(mail out.Mail) error {
+	var html, text bytes.Buffer
+
+	if err := m.html.ExecuteTemplate(&html, mail.Template+".html.tmpl", mail.Data); err != nil {
+		return fmt.Errorf("failed to render html mail %q: %w", mail.Template, err)
+	}
+	if err := m.text.ExecuteTemplate(&text, mail.Template+".txt.tmpl", mail.Data); err != nil {
+		return fmt.Errorf("failed to render text mail %q: %w", mail.Template, err)
+	}
+
+	return m.transport.Deliver(Message{
+		From:     m.from,
+		To:       mail.To,
+		Subject:  mail.Subject,
+		Template: mail.Template,
+		TextBody: text.String(),
+		HTMLBody: html.String(),
+	})
+}
+
+// NewTransport returns the transport selected by MAIL_DRIVER. There is no
+// default, so that a missing setting fails at startup instead of quietly
+// dropping every mail.
+func NewTransport() (Transport, error) {
+	switch driver := config.AppConfig.MailDriver; driver {
+	case "":
+		return nil, errors.New("no mail driver configured")
+	case "log":
+		return NewLogTransport(), nil
+	case "smtp":
+		return NewSMTPTransport(
+			config.AppConfig.SMTPHost,
+			config.AppConfig.SMTPPort,
+			config.AppConfig.SMTPUsername,
+			config.AppConfig.SMTPPassword,
+		), nil
+	case "file":
+		return NewFileTransport(config.AppConfig.MailFileDir)
+	case "memory":
+		return NewMemoryTransport(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", driver)
+	}
+}
@@ -0,0 +1,39 @@
+package mailers
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// SMTPTransport delivers mail through an SMTP relay, upgrading to TLS when the
+// server offers STARTTLS.
+type SMTPTransport struct {
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPTransport(host, port, username, password string) *SMTPTransport {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPTransport{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+	}
+}
+
+func (t *SMTPTransport) Deliver(msg Message) error {
+	body, err := buildMIME(msg)
+	if err != nil {
+		return fmt.Errorf("failed to build mail: %w", err)
+	}
+
+	if err := smtp.SendMail(t.addr, t.auth, msg.From, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("failed to send mail via smtp: %w", err)
+	}
+
+	return nil
+}
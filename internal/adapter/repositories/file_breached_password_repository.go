@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+const (
+	breachedHashLength   = 40 // Hex characters of a SHA-1 hash
+	breachedPrefixLength = 5
+)
+
+// FileBreachedPasswordRepository reads breached password hashes from a
+// directory holding one "<PREFIX>.txt" file per hash prefix, each line being
+// "<SUFFIX>:<COUNT>". This is the layout of the Pwned Passwords range files,
+// so a downloaded corpus can be used as is. Without a directory configured no
+// password is reported as breached.
+type FileBreachedPasswordRepository struct {
+	dir string
+}
+
+func NewFileBreachedPasswordRepository() *FileBreachedPasswordRepository {
+	return &FileBreachedPasswordRepository{
+		dir: config.AppConfig.BreachedPasswordDir,
+	}
+}
+
+func (r *FileBreachedPasswordRepository) GetRange(prefix string) (map[string]int, error) {
+	if r.dir == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(r.rangePath(prefix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	suffixes := make(map[string]int)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if suffix == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			n = 1
+		}
+		suffixes[strings.ToUpper(suffix)] = n
+	}
+
+	return suffixes, scanner.Err()
+}
+
+// Import adds SHA-1 hashes, one "<HASH>" or "<HASH>:<COUNT>" per line, to the
+// corpus. Input sorted by hash, like the Pwned Passwords ordered-by-hash
+// download, is written one range file at a time. Each range is merged with
+// the hashes already in it and replaced as a whole, so importing the same
+// hashes again leaves the corpus unchanged; a known hash takes the new count.
+func (r *FileBreachedPasswordRepository) Import(hashes io.Reader) (int, error) {
+	if r.dir == "" {
+		return 0, errors.New("no breached password directory configured")
+	}
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create breached password directory: %w", err)
+	}
+
+	var (
+		imported int
+		prefix   string
+		suffixes map[string]int
+	)
+
+	scanner := bufio.NewScanner(hashes)
+	for scanner.Scan() {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != breachedHashLength {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			continue
+		}
+
+		hash = strings.ToUpper(hash)
+		if hash[:breachedPrefixLength] != prefix {
+			if suffixes != nil {
+				if err := r.writeRange(prefix, suffixes); err != nil {
+					return imported, err
+				}
+			}
+
+			prefix = hash[:breachedPrefixLength]
+
+			existing, err := r.GetRange(prefix)
+			if err != nil {
+				return imported, err
+			}
+			suffixes = existing
+			if suffixes == nil {
+				suffixes = make(map[string]int)
+			}
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			n = 1
+		}
+		suffixes[hash[breachedPrefixLength:]] = n
+		imported++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+
+	if suffixes != nil {
+		if err := r.writeRange(prefix, suffixes); err != nil {
+			return imported, err
+		}
+	}
+
+	return imported, nil
+}
+
+// writeRange replaces the range file of the prefix. The suffixes are written
+// to a temporary file that is renamed over the range, so that a failed import
+// never leaves a partly written range behind.
+func (r *FileBreachedPasswordRepository) writeRange(prefix string, suffixes map[string]int) error {
+	file, err := os.CreateTemp(r.dir, strings.ToUpper(prefix)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	writer := bufio.NewWriter(file)
+	for _, suffix := range slices.Sorted(maps.Keys(suffixes)) {
+		if _, err := fmt.Fprintf(writer, "%s:%d\n", suffix, suffixes[suffix]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), r.rangePath(prefix))
+}
+
+func (r *FileBreachedPasswordRepository) rangePath(prefix string) string {
+	return filepath.Join(r.dir, strings.ToUpper(prefix)+".txt")
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/database"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultLoginAttemptStore = "redis"
+
+// NewLoginAttemptRepository returns the store selected by LOGIN_ATTEMPT_STORE.
+// Redis is only connected to when it is selected.
+func NewLoginAttemptRepository(db *pgxpool.Pool) (out.LoginAttemptRepository, func(), error) {
+	driver := config.AppConfig.LoginAttemptStore
+	if driver == "" {
+		driver = defaultLoginAttemptStore
+	}
+
+	switch driver {
+	case "redis":
+		pool, err := database.NewRedisPool()
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewRedisLoginAttemptRepository(pool), func() { pool.Close() }, nil
+	case "postgres":
+		return NewPostgresLoginAttemptRepository(db), func() {}, nil
+	case "memory":
+		return NewMemoryLoginAttemptRepository(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown login attempt store: %s", driver)
+	}
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryLoginAttempt struct {
+	failures         int
+	lastFailureAt    time.Time
+	failuresExpireAt time.Time
+	lockedUntil      time.Time
+}
+
+// MemoryLoginAttemptRepository keeps failed attempts in the process. They do
+// not survive a restart and are not shared between instances, so it is meant
+// for tests and single-node development.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryLoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{
+		attempts: make(map[string]*memoryLoginAttempt),
+	}
+}
+
+// RecordFailure counts a failed attempt and returns the failures within the
+// window. The window restarts with every failure.
+func (r *MemoryLoginAttemptRepository) RecordFailure(key string, window time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	// Keys with neither failures nor a lockout left are cleaned up as new
+	// failures come in.
+	for k, attempt := range r.attempts {
+		if !now.Before(attempt.failuresExpireAt) && !now.Before(attempt.lockedUntil) {
+			delete(r.attempts, k)
+		}
+	}
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &memoryLoginAttempt{}
+		r.attempts[key] = attempt
+	}
+
+	if !now.Before(attempt.failuresExpireAt) {
+		attempt.failures = 0
+	}
+	attempt.failures++
+	attempt.lastFailureAt = now
+	attempt.failuresExpireAt = now.Add(window)
+
+	return attempt.failures, nil
+}
+
+func (r *MemoryLoginAttemptRepository) GetFailures(key string) (int, *time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok || !time.Now().Before(attempt.failuresExpireAt) {
+		return 0, nil, nil
+	}
+
+	lastFailureAt := attempt.lastFailureAt
+	return attempt.failures, &lastFailureAt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) ResetFailures(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.failures = 0
+		attempt.lastFailureAt = time.Time{}
+		attempt.failuresExpireAt = time.Time{}
+	}
+
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &memoryLoginAttempt{}
+		r.attempts[key] = attempt
+	}
+	attempt.lockedUntil = until
+
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) LockedUntil(key string) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok || !time.Now().Before(attempt.lockedUntil) {
+		return nil, nil
+	}
+
+	lockedUntil := attempt.lockedUntil
+	return &lockedUntil, nil
+}
+
+// Unlock lifts the lockout and forgets the failures that caused it.
+func (r *MemoryLoginAttemptRepository) Unlock(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
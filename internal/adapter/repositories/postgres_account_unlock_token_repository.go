@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAccountUnlockTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAccountUnlockTokenRepository(db *pgxpool.Pool) *PostgresAccountUnlockTokenRepository {
+	return &PostgresAccountUnlockTokenRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresAccountUnlockTokenRepository) CreateAccountUnlockToken(token domain.AccountUnlockToken) (domain.AccountUnlockToken, error) {
+	query := `
+		INSERT INTO account_unlock_tokens (id, token_hash, user_id, expires_at)
+		VALUES (@id, @token_hash, @user_id, @expires_at)
+		RETURNING created_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":         token.ID,
+		"token_hash": token.TokenHash,
+		"user_id":    token.UserID,
+		"expires_at": token.ExpiresAt,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&token.CreatedAt); err != nil {
+		return domain.AccountUnlockToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *PostgresAccountUnlockTokenRepository) GetAccountUnlockToken(tokenHash string) (domain.AccountUnlockToken, error) {
+	query := `
+		SELECT id, token_hash, user_id, expires_at, consumed_at, created_at
+		FROM account_unlock_tokens WHERE token_hash = @token_hash
+	`
+
+	var token domain.AccountUnlockToken
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"token_hash": tokenHash}).Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.UserID,
+		&token.ExpiresAt,
+		&token.ConsumedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.AccountUnlockToken{}, domain.ErrAccountUnlockTokenNotFound
+		}
+		return domain.AccountUnlockToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *PostgresAccountUnlockTokenRepository) ConsumeAccountUnlockToken(tokenID string) error {
+	query := `
+		UPDATE account_unlock_tokens SET consumed_at = CURRENT_TIMESTAMP
+		WHERE id = @id AND consumed_at IS NULL AND expires_at > NOW()
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"id": tokenID})
+	if err != nil {
+		return err
+	}
+
+	// The token was consumed by a concurrent request or expired since it was
+	// read.
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidUnlockToken
+	}
+
+	return nil
+}
+
+func (r *PostgresAccountUnlockTokenRepository) ListAccountUnlockTokens(userID int64) ([]domain.AccountUnlockToken, error) {
+	query := `
+		SELECT id, token_hash, user_id, expires_at, consumed_at, created_at FROM account_unlock_tokens
+		WHERE user_id = @user_id ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []domain.AccountUnlockToken{}
+	for rows.Next() {
+		var token domain.AccountUnlockToken
+		err := rows.Scan(
+			&token.ID,
+			&token.TokenHash,
+			&token.UserID,
+			&token.ExpiresAt,
+			&token.ConsumedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresDataExportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresDataExportRepository(db *pgxpool.Pool) *PostgresDataExportRepository {
+	return &PostgresDataExportRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresDataExportRepository) CreateDataExport(export domain.DataExport) (domain.DataExport, error) {
+	query := `
+		INSERT INTO data_exports (id, user_id, status)
+		VALUES (@id, @user_id, @status)
+		RETURNING created_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":      export.ID,
+		"user_id": export.UserID,
+		"status":  export.Status,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&export.CreatedAt); err != nil {
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+func (r *PostgresDataExportRepository) GetDataExport(exportID string) (domain.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, file_path, expires_at, completed_at, created_at FROM data_exports WHERE id = @id
+	`
+	return r.queryDataExport(query, pgx.NamedArgs{"id": exportID})
+}
+
+func (r *PostgresDataExportRepository) GetLatestDataExport(userID int64) (domain.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, file_path, expires_at, completed_at, created_at FROM data_exports
+		WHERE user_id = @user_id ORDER BY created_at DESC LIMIT 1
+	`
+	return r.queryDataExport(query, pgx.NamedArgs{"user_id": userID})
+}
+
+func (r *PostgresDataExportRepository) queryDataExport(query string, args pgx.NamedArgs) (domain.DataExport, error) {
+	var export domain.DataExport
+
+	err := r.db.QueryRow(context.Background(), query, args).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.FilePath,
+		&export.ExpiresAt,
+		&export.CompletedAt,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.DataExport{}, domain.ErrDataExportNotFound
+		}
+		return domain.DataExport{}, err
+	}
+
+	return export, nil
+}
+
+func (r *PostgresDataExportRepository) UpdateDataExport(export domain.DataExport) error {
+	query := `
+		UPDATE data_exports SET status = @status, file_path = @file_path, expires_at = @expires_at, completed_at = @completed_at
+		WHERE id = @id
+	`
+
+	args := pgx.NamedArgs{
+		"id":           export.ID,
+		"status":       export.Status,
+		"file_path":    export.FilePath,
+		"expires_at":   export.ExpiresAt,
+		"completed_at": export.CompletedAt,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrDataExportNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresEmailVerificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresEmailVerificationRepository(db *pgxpool.Pool) *PostgresEmailVerificationRepository {
+	return &PostgresEmailVerificationRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresEmailVerificationRepository) CreateEmailVerification(verification domain.EmailVerification) (domain.EmailVerification, error) {
+	query := `
+		INSERT INTO email_verifications (id, user_id, email, expires_at)
+		VALUES (@id, @user_id, @email, @expires_at)
+		RETURNING created_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":         verification.ID,
+		"user_id":    verification.UserID,
+		"email":      verification.Email,
+		"expires_at": verification.ExpiresAt,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&verification.CreatedAt); err != nil {
+		return domain.EmailVerification{}, err
+	}
+
+	return verification, nil
+}
+
+func (r *PostgresEmailVerificationRepository) GetEmailVerification(verificationID string) (domain.EmailVerification, error) {
+	query := `
+		SELECT id, user_id, email, expires_at, used_at, created_at FROM email_verifications WHERE id = @id
+	`
+
+	var verification domain.EmailVerification
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"id": verificationID}).Scan(
+		&verification.ID,
+		&verification.UserID,
+		&verification.Email,
+		&verification.ExpiresAt,
+		&verification.UsedAt,
+		&verification.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.EmailVerification{}, domain.ErrEmailVerificationNotFound
+		}
+		return domain.EmailVerification{}, err
+	}
+
+	return verification, nil
+}
+
+func (r *PostgresEmailVerificationRepository) MarkEmailVerificationUsed(verificationID string) error {
+	query := `
+		UPDATE email_verifications SET used_at = CURRENT_TIMESTAMP WHERE id = @id AND used_at IS NULL
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"id": verificationID})
+	if err != nil {
+		return err
+	}
+
+	// Another request consumed the token between reading and marking it.
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+func (r *PostgresEmailVerificationRepository) CountEmailVerificationsSince(userID int64, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM email_verifications WHERE user_id = @user_id AND created_at >= @since
+	`
+
+	var count int
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID, "since": since}).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresEmailVerificationRepository) LatestEmailVerificationAt(userID int64) (*time.Time, error) {
+	query := `
+		SELECT MAX(created_at) FROM email_verifications WHERE user_id = @user_id
+	`
+
+	var latest *time.Time
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(&latest)
+	return latest, err
+}
+
+func (r *PostgresEmailVerificationRepository) ListEmailVerifications(userID int64) ([]domain.EmailVerification, error) {
+	query := `
+		SELECT id, user_id, email, expires_at, used_at, created_at FROM email_verifications
+		WHERE user_id = @user_id ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifications := []domain.EmailVerification{}
+	for rows.Next() {
+		var verification domain.EmailVerification
+		err := rows.Scan(
+			&verification.ID,
+			&verification.UserID,
+			&verification.Email,
+			&verification.ExpiresAt,
+			&verification.UsedAt,
+			&verification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		verifications = append(verifications, verification)
+	}
+
+	return verifications, rows.Err()
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresLoginAttemptRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresLoginAttemptRepository(db *pgxpool.Pool) *PostgresLoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{
+		db: db,
+	}
+}
+
+// RecordFailure counts a failed attempt and returns the failures within the
+// window. The window restarts with every failure.
+func (r *PostgresLoginAttemptRepository) RecordFailure(key string, window time.Duration) (int, error) {
+	// Keys with neither failures nor a lockout left are cleaned up as new
+	// failures come in.
+	query := `
+		DELETE FROM login_attempts
+		WHERE (failures_expire_at IS NULL OR failures_expire_at <= NOW())
+		AND (locked_until IS NULL OR locked_until <= NOW())
+	`
+
+	if _, err := r.db.Exec(context.Background(), query); err != nil {
+		return 0, err
+	}
+
+	query = `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at, failures_expire_at)
+		VALUES (@attempt_key, 1, NOW(), @failures_expire_at)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.failures_expire_at > NOW() THEN login_attempts.failures + 1
+				ELSE 1
+			END,
+			last_failure_at = NOW(),
+			failures_expire_at = EXCLUDED.failures_expire_at
+		RETURNING failures
+	`
+
+	args := pgx.NamedArgs{
+		"attempt_key":        key,
+		"failures_expire_at": time.Now().Add(window),
+	}
+
+	var failures int
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (r *PostgresLoginAttemptRepository) GetFailures(key string) (int, *time.Time, error) {
+	query := `
+		SELECT failures, last_failure_at FROM login_attempts
+		WHERE attempt_key = @attempt_key AND failures_expire_at > NOW()
+	`
+
+	var failures int
+	var lastFailureAt *time.Time
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"attempt_key": key}).Scan(&failures, &lastFailureAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return failures, lastFailureAt, nil
+}
+
+func (r *PostgresLoginAttemptRepository) ResetFailures(key string) error {
+	query := `
+		UPDATE login_attempts SET failures = 0, last_failure_at = NULL, failures_expire_at = NULL
+		WHERE attempt_key = @attempt_key
+	`
+
+	_, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"attempt_key": key})
+	return err
+}
+
+func (r *PostgresLoginAttemptRepository) Lock(key string, until time.Time) error {
+	query := `
+		INSERT INTO login_attempts (attempt_key, locked_until)
+		VALUES (@attempt_key, @locked_until)
+		ON CONFLICT (attempt_key) DO UPDATE SET locked_until = EXCLUDED.locked_until
+	`
+
+	args := pgx.NamedArgs{
+		"attempt_key":  key,
+		"locked_until": until,
+	}
+
+	_, err := r.db.Exec(context.Background(), query, args)
+	return err
+}
+
+func (r *PostgresLoginAttemptRepository) LockedUntil(key string) (*time.Time, error) {
+	query := `
+		SELECT locked_until FROM login_attempts
+		WHERE attempt_key = @attempt_key AND locked_until > NOW()
+	`
+
+	var lockedUntil time.Time
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"attempt_key": key}).Scan(&lockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockedUntil, nil
+}
+
+// Unlock lifts the lockout and forgets the failures that caused it.
+func (r *PostgresLoginAttemptRepository) Unlock(key string) error {
+	query := `DELETE FROM login_attempts WHERE attempt_key = @attempt_key`
+
+	_, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"attempt_key": key})
+	return err
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresMagicLinkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMagicLinkRepository(db *pgxpool.Pool) *PostgresMagicLinkRepository {
+	return &PostgresMagicLinkRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresMagicLinkRepository) CreateMagicLink(link domain.MagicLink) (domain.MagicLink, error) {
+	query := `
+		INSERT INTO magic_links (id, user_id, email, device_hash, expires_at)
+		VALUES (@id, @user_id, @email, @device_hash, @expires_at)
+		RETURNING created_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":          link.ID,
+		"user_id":     link.UserID,
+		"email":       link.Email,
+		"device_hash": link.DeviceHash,
+		"expires_at":  link.ExpiresAt,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&link.CreatedAt); err != nil {
+		return domain.MagicLink{}, err
+	}
+
+	return link, nil
+}
+
+func (r *PostgresMagicLinkRepository) GetMagicLink(linkID string) (domain.MagicLink, error) {
+	query := `
+		SELECT id, user_id, email, device_hash, expires_at, used_at, created_at FROM magic_links WHERE id = @id
+	`
+
+	var link domain.MagicLink
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"id": linkID}).Scan(
+		&link.ID,
+		&link.UserID,
+		&link.Email,
+		&link.DeviceHash,
+		&link.ExpiresAt,
+		&link.UsedAt,
+		&link.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.MagicLink{}, domain.ErrMagicLinkNotFound
+		}
+		return domain.MagicLink{}, err
+	}
+
+	return link, nil
+}
+
+func (r *PostgresMagicLinkRepository) MarkMagicLinkUsed(linkID string) error {
+	query := `
+		UPDATE magic_links SET used_at = CURRENT_TIMESTAMP WHERE id = @id AND used_at IS NULL
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"id": linkID})
+	if err != nil {
+		return err
+	}
+
+	// Another request consumed the link between reading and marking it.
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidMagicLink
+	}
+
+	return nil
+}
+
+func (r *PostgresMagicLinkRepository) LatestMagicLinkAt(userID int64) (*time.Time, error) {
+	query := `
+		SELECT MAX(created_at) FROM magic_links WHERE user_id = @user_id
+	`
+
+	var latest *time.Time
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(&latest)
+	return latest, err
+}
+
+func (r *PostgresMagicLinkRepository) ListMagicLinks(userID int64) ([]domain.MagicLink, error) {
+	query := `
+		SELECT id, user_id, email, device_hash, expires_at, used_at, created_at FROM magic_links
+		WHERE user_id = @user_id ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []domain.MagicLink{}
+	for rows.Next() {
+		var link domain.MagicLink
+		err := rows.Scan(
+			&link.ID,
+			&link.UserID,
+			&link.Email,
+			&link.DeviceHash,
+			&link.ExpiresAt,
+			&link.UsedAt,
+			&link.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
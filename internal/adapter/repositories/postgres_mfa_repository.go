@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresMFARepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresMFARepository(db *pgxpool.Pool) *PostgresMFARepository {
+	return &PostgresMFARepository{
+		db: db,
+	}
+}
+
+func (r *PostgresMFARepository) GetTOTP(userID int64) (domain.TOTP, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_totp WHERE user_id = @user_id
+	`
+
+	var totp domain.TOTP
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.EnabledAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.TOTP{}, domain.ErrTOTPNotEnrolled
+		}
+		return domain.TOTP{}, err
+	}
+
+	return totp, nil
+}
+
+// SaveTOTP stores a new, not yet enabled secret, replacing any unconfirmed one.
+func (r *PostgresMFARepository) SaveTOTP(totp domain.TOTP) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES (@user_id, @secret)
+		ON CONFLICT (user_id)
+		DO UPDATE SET secret = EXCLUDED.secret, enabled_at = NULL, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled_at IS NULL
+	`
+
+	args := pgx.NamedArgs{
+		"user_id": totp.UserID,
+		"secret":  totp.Secret,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) EnableTOTP(userID int64) error {
+	query := `
+		UPDATE user_totp SET enabled_at = CURRENT_TIMESTAMP WHERE user_id = @user_id AND enabled_at IS NULL
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. A step at or before
+// the last accepted one means the code is being replayed.
+func (r *PostgresMFARepository) UseTOTPStep(userID int64, step int64) error {
+	query := `
+		UPDATE user_totp SET last_used_step = @step WHERE user_id = @user_id AND last_used_step < @step
+	`
+
+	args := pgx.NamedArgs{
+		"user_id": userID,
+		"step":    step,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) DeleteTOTP(userID int64) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	args := pgx.NamedArgs{"user_id": userID}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = @user_id`, args); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = @user_id`, args); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = @user_id`, pgx.NamedArgs{"user_id": userID}); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		args := pgx.NamedArgs{
+			"user_id":   userID,
+			"code_hash": codeHash,
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (@user_id, @code_hash)`, args); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresMFARepository) UseRecoveryCode(userID int64, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = @user_id AND code_hash = @code_hash AND used_at IS NULL
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":   userID,
+		"code_hash": codeHash,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (r *PostgresMFARepository) CountRecoveryCodes(userID int64) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = @user_id AND used_at IS NULL
+	`
+
+	var count int
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresMFARepository) ListRecoveryCodes(userID int64) ([]domain.RecoveryCode, error) {
+	query := `
+		SELECT user_id, code_hash, used_at, created_at FROM mfa_recovery_codes
+		WHERE user_id = @user_id ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []domain.RecoveryCode{}
+	for rows.Next() {
+		var code domain.RecoveryCode
+		err := rows.Scan(
+			&code.UserID,
+			&code.CodeHash,
+			&code.UsedAt,
+			&code.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPasswordResetRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPasswordResetRepository(db *pgxpool.Pool) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresPasswordResetRepository) CreatePasswordReset(reset domain.PasswordReset) (domain.PasswordReset, error) {
+	query := `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES (@user_id, @token_hash, @expires_at)
+		RETURNING id, created_at
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":    reset.UserID,
+		"token_hash": reset.TokenHash,
+		"expires_at": reset.ExpiresAt,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&reset.ID, &reset.CreatedAt); err != nil {
+		return domain.PasswordReset{}, err
+	}
+
+	return reset, nil
+}
+
+func (r *PostgresPasswordResetRepository) GetPasswordResetByTokenHash(tokenHash string) (domain.PasswordReset, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_resets WHERE token_hash = @token_hash
+	`
+
+	var reset domain.PasswordReset
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"token_hash": tokenHash}).Scan(
+		&reset.ID,
+		&reset.UserID,
+		&reset.TokenHash,
+		&reset.ExpiresAt,
+		&reset.UsedAt,
+		&reset.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.PasswordReset{}, domain.ErrPasswordResetNotFound
+		}
+		return domain.PasswordReset{}, err
+	}
+
+	return reset, nil
+}
+
+func (r *PostgresPasswordResetRepository) MarkPasswordResetUsed(resetID int64) error {
+	query := `
+		UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE id = @id AND used_at IS NULL
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"id": resetID})
+	if err != nil {
+		return err
+	}
+
+	// Another request consumed the token between reading and marking it.
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidPasswordResetToken
+	}
+
+	return nil
+}
+
+func (r *PostgresPasswordResetRepository) InvalidatePasswordResets(userID int64) error {
+	query := `
+		UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE user_id = @user_id AND used_at IS NULL
+	`
+
+	_, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	return err
+}
+
+func (r *PostgresPasswordResetRepository) LatestPasswordResetAt(userID int64) (*time.Time, error) {
+	query := `
+		SELECT MAX(created_at) FROM password_resets WHERE user_id = @user_id
+	`
+
+	var latest *time.Time
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(&latest)
+	return latest, err
+}
+
+func (r *PostgresPasswordResetRepository) ListPasswordResets(userID int64) ([]domain.PasswordReset, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_resets
+		WHERE user_id = @user_id ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resets := []domain.PasswordReset{}
+	for rows.Next() {
+		var reset domain.PasswordReset
+		err := rows.Scan(
+			&reset.ID,
+			&reset.UserID,
+			&reset.TokenHash,
+			&reset.ExpiresAt,
+			&reset.UsedAt,
+			&reset.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		resets = append(resets, reset)
+	}
+
+	return resets, rows.Err()
+}
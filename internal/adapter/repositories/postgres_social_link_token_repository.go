@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSocialLinkTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSocialLinkTokenRepository(db *pgxpool.Pool) *PostgresSocialLinkTokenRepository {
+	return &PostgresSocialLinkTokenRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresSocialLinkTokenRepository) CreateSocialLinkToken(token domain.SocialLinkToken) (domain.SocialLinkToken, error) {
+	query := `
+		INSERT INTO social_link_tokens (id, token_hash, user_id, social_account_id, expires_at)
+		VALUES (@id, @token_hash, @user_id, @social_account_id, @expires_at)
+		RETURNING created_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":                token.ID,
+		"token_hash":        token.TokenHash,
+		"user_id":           token.UserID,
+		"social_account_id": token.SocialAccountID,
+		"expires_at":        token.ExpiresAt,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&token.CreatedAt); err != nil {
+		return domain.SocialLinkToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *PostgresSocialLinkTokenRepository) GetSocialLinkToken(tokenHash string) (domain.SocialLinkToken, error) {
+	query := `
+		SELECT id, token_hash, user_id, social_account_id, expires_at, consumed_at, created_at
+		FROM social_link_tokens WHERE token_hash = @token_hash
+	`
+
+	var token domain.SocialLinkToken
+
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"token_hash": tokenHash}).Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.UserID,
+		&token.SocialAccountID,
+		&token.ExpiresAt,
+		&token.ConsumedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.SocialLinkToken{}, domain.ErrSocialLinkTokenNotFound
+		}
+		return domain.SocialLinkToken{}, err
+	}
+
+	return token, nil
+}
+
+func (r *PostgresSocialLinkTokenRepository) ConsumeSocialLinkToken(tokenID string) error {
+	query := `
+		UPDATE social_link_tokens SET consumed_at = CURRENT_TIMESTAMP
+		WHERE id = @id AND consumed_at IS NULL AND expires_at > NOW()
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"id": tokenID})
+	if err != nil {
+		return err
+	}
+
+	// The token was consumed by a concurrent request or expired since it was
+	// read.
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrInvalidLinkToken
+	}
+
+	return nil
+}
@@ -4,35 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Joe5451/go-oauth2-server/internal/domain"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresUserRepository struct {
-	conn *pgx.Conn
+	db *pgxpool.Pool
 }
 
-func NewPostgresUserRepository(conn *pgx.Conn) *PostgresUserRepository {
+func NewPostgresUserRepository(db *pgxpool.Pool) *PostgresUserRepository {
 	return &PostgresUserRepository{
-		conn: conn,
+		db: db,
 	}
 }
 
 func (r *PostgresUserRepository) CreateUser(user domain.User) (domain.User, error) {
 	query := `
-		INSERT INTO users (email, password, name, avatar) VALUES (@email, @password, @name, @avatar) RETURNING id, email, name, avatar
+		INSERT INTO users (email, pending_email, password, name, avatar, email_verified_at)
+		VALUES (@email, @pending_email, NULLIF(@password, ''), @name, @avatar, @email_verified_at)
+		RETURNING id, email, pending_email, name, avatar, email_verified_at, updated_at
 	`
 
 	args := pgx.NamedArgs{
-		"email":    user.Email,
-		"password": user.Password,
-		"name":     user.Name,
-		"avatar":   user.Avatar,
+		"email":             user.Email,
+		"pending_email":     user.PendingEmail,
+		"password":          user.Password,
+		"name":              user.Name,
+		"avatar":            user.Avatar,
+		"email_verified_at": user.EmailVerifiedAt,
 	}
 
-	err := r.conn.QueryRow(context.Background(), query, args).Scan(&user.ID, &user.Email, &user.Name, &user.Avatar)
+	err := r.db.QueryRow(context.Background(), query, args).Scan(&user.ID, &user.Email, &user.PendingEmail, &user.Name, &user.Avatar, &user.EmailVerifiedAt, &user.UpdatedAt)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -46,7 +52,10 @@ func (r *PostgresUserRepository) CreateUser(user domain.User) (domain.User, erro
 
 func (r *PostgresUserRepository) GetUser(userID int64) (domain.User, error) {
 	query := `
-		SELECT u.id, u.email, u.password, u.name, u.avatar,
+		SELECT u.id, u.email, u.pending_email, u.password, u.name, u.avatar,
+		       u.email_verified_at, u.sessions_revoked_at, u.deleted_at, u.created_at, u.updated_at,
+		       (EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL)
+		        OR EXISTS (SELECT 1 FROM webauthn_credentials w WHERE w.user_id = u.id)) AS mfa_enabled,
 		       s.id AS social_account_id, s.provider, s.provider_user_id, s.email, s.name, s.avatar
 		FROM users u
 		LEFT JOIN social_accounts s ON u.id = s.user_id
@@ -57,7 +66,10 @@ func (r *PostgresUserRepository) GetUser(userID int64) (domain.User, error) {
 
 func (r *PostgresUserRepository) GetUserByEmail(email string) (domain.User, error) {
 	query := `
-		SELECT u.id, u.email, u.password, u.name, u.avatar,
+		SELECT u.id, u.email, u.pending_email, u.password, u.name, u.avatar,
+		       u.email_verified_at, u.sessions_revoked_at, u.deleted_at, u.created_at, u.updated_at,
+		       (EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL)
+		        OR EXISTS (SELECT 1 FROM webauthn_credentials w WHERE w.user_id = u.id)) AS mfa_enabled,
 		       s.id AS social_account_id, s.provider, s.provider_user_id, s.email, s.name, s.avatar
 		FROM users u
 		LEFT JOIN social_accounts s ON u.id = s.user_id
@@ -66,8 +78,24 @@ func (r *PostgresUserRepository) GetUserByEmail(email string) (domain.User, erro
 	return r.queryUserByArgs(query, pgx.NamedArgs{"email": email})
 }
 
+// GetUserByPendingEmail returns the user most recently waiting to change their
+// email to this one. Pending emails are not unique, as they are not verified.
+func (r *PostgresUserRepository) GetUserByPendingEmail(email string) (domain.User, error) {
+	query := `
+		SELECT u.id, u.email, u.pending_email, u.password, u.name, u.avatar,
+		       u.email_verified_at, u.sessions_revoked_at, u.deleted_at, u.created_at, u.updated_at,
+		       (EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL)
+		        OR EXISTS (SELECT 1 FROM webauthn_credentials w WHERE w.user_id = u.id)) AS mfa_enabled,
+		       s.id AS social_account_id, s.provider, s.provider_user_id, s.email, s.name, s.avatar
+		FROM users u
+		LEFT JOIN social_accounts s ON u.id = s.user_id
+		WHERE u.id = (SELECT id FROM users WHERE pending_email = @email ORDER BY updated_at DESC LIMIT 1)
+	`
+	return r.queryUserByArgs(query, pgx.NamedArgs{"email": email})
+}
+
 func (r *PostgresUserRepository) queryUserByArgs(query string, args pgx.NamedArgs) (domain.User, error) {
-	rows, err := r.conn.Query(context.Background(), query, args)
+	rows, err := r.db.Query(context.Background(), query, args)
 	if err != nil {
 		return domain.User{}, err
 	}
@@ -79,14 +107,18 @@ func (r *PostgresUserRepository) queryUserByArgs(query string, args pgx.NamedArg
 	for rows.Next() {
 		var accountID *int64                                      // Nullable social account ID, as a user may not have one.
 		var provider, providerUserID, email, name, avatar *string // Nullable fields for social account details.
+		var password *string                                      // Nullable for social-only users.
 
 		err := rows.Scan(
-			&user.ID, &user.Email, &user.Password, &user.Name, &user.Avatar,
+			&user.ID, &user.Email, &user.PendingEmail, &password, &user.Name, &user.Avatar, &user.EmailVerifiedAt, &user.SessionsRevokedAt, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.MFAEnabled,
 			&accountID, &provider, &providerUserID, &email, &name, &avatar,
 		)
 		if err != nil {
 			return domain.User{}, fmt.Errorf("Error Fetching user and social account: %w", err)
 		}
+		if password != nil {
+			user.Password = *password
+		}
 		if accountID != nil {
 			user.SocialAccounts = append(user.SocialAccounts, domain.SocialAccount{
 				ID:             *accountID,
@@ -108,15 +140,16 @@ func (r *PostgresUserRepository) queryUserByArgs(query string, args pgx.NamedArg
 
 func (r *PostgresUserRepository) UpdateOrCreateSocialAccount(socialAccount domain.SocialAccount) (domain.SocialAccount, error) {
 	query := `
-		INSERT INTO social_accounts (user_id, provider, provider_user_id, email, name, avatar)
-		VALUES (@user_id, @provider, @provider_user_id, @email, @name, @avatar)
+		INSERT INTO social_accounts (user_id, provider, provider_user_id, email, email_verified, name, avatar)
+		VALUES (@user_id, @provider, @provider_user_id, @email, @email_verified, @name, @avatar)
 		ON CONFLICT (provider, provider_user_id)
 		DO UPDATE SET
 			email = EXCLUDED.email,
+			email_verified = EXCLUDED.email_verified,
 			name = EXCLUDED.name,
 			avatar = EXCLUDED.avatar,
 			updated_at = CURRENT_TIMESTAMP
-		RETURNING id, user_id, provider, provider_user_id, email, name, avatar, created_at, updated_at
+		RETURNING id, user_id, provider, provider_user_id, email, email_verified, name, avatar, created_at, updated_at
 	`
 
 	args := pgx.NamedArgs{
@@ -124,16 +157,18 @@ func (r *PostgresUserRepository) UpdateOrCreateSocialAccount(socialAccount domai
 		"provider":         socialAccount.Provider,
 		"provider_user_id": socialAccount.ProviderUserID,
 		"email":            socialAccount.Email,
+		"email_verified":   socialAccount.EmailVerified,
 		"name":             socialAccount.Name,
 		"avatar":           socialAccount.Avatar,
 	}
 
-	err := r.conn.QueryRow(context.Background(), query, args).Scan(
+	err := r.db.QueryRow(context.Background(), query, args).Scan(
 		&socialAccount.ID,
 		&socialAccount.UserID,
 		&socialAccount.Provider,
 		&socialAccount.ProviderUserID,
 		&socialAccount.Email,
+		&socialAccount.EmailVerified,
 		&socialAccount.Name,
 		&socialAccount.Avatar,
 		&socialAccount.CreatedAt,
@@ -147,18 +182,108 @@ func (r *PostgresUserRepository) UpdateOrCreateSocialAccount(socialAccount domai
 	return socialAccount, nil
 }
 
-func (r *PostgresUserRepository) GetSocialAccountByProviderUserID(providerUserID string) (domain.SocialAccount, error) {
+func (r *PostgresUserRepository) ListSocialAccounts(userID int64) ([]domain.SocialAccount, error) {
 	query := `
-		SELECT id, provider, provider_user_id, user_id, created_at, updated_at FROM social_accounts WHERE provider_user_id = @provider_user_id
+		SELECT id, user_id, provider, provider_user_id, email, email_verified, name, avatar, created_at, updated_at
+		FROM social_accounts WHERE user_id = @user_id ORDER BY id
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	socialAccounts := []domain.SocialAccount{}
+	for rows.Next() {
+		var socialAccount domain.SocialAccount
+		err := rows.Scan(
+			&socialAccount.ID,
+			&socialAccount.UserID,
+			&socialAccount.Provider,
+			&socialAccount.ProviderUserID,
+			&socialAccount.Email,
+			&socialAccount.EmailVerified,
+			&socialAccount.Name,
+			&socialAccount.Avatar,
+			&socialAccount.CreatedAt,
+			&socialAccount.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		socialAccounts = append(socialAccounts, socialAccount)
+	}
+
+	return socialAccounts, rows.Err()
+}
+
+// GetLoginMethods returns the password, linked providers and passkeys the
+// user can sign in with.
+func (r *PostgresUserRepository) GetLoginMethods(userID int64) (domain.LoginMethods, error) {
+	return queryLoginMethods(context.Background(), r.db, userID)
+}
+
+// lockLoginMethods locks the user's row until the transaction ends and
+// returns their login methods. Removals of login methods take the lock first,
+// so that concurrent removals cannot each leave the other's method as the
+// last one and then both remove it.
+func lockLoginMethods(ctx context.Context, tx pgx.Tx, userID int64) (domain.LoginMethods, error) {
+	var id int64
+	err := tx.QueryRow(ctx, `SELECT id FROM users WHERE id = @user_id FOR UPDATE`, pgx.NamedArgs{"user_id": userID}).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.LoginMethods{}, domain.ErrUserNotFound
+		}
+		return domain.LoginMethods{}, err
+	}
+
+	return queryLoginMethods(ctx, tx, userID)
+}
+
+func queryLoginMethods(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}, userID int64) (domain.LoginMethods, error) {
+	query := `
+		SELECT
+			COALESCE(password, '') <> '',
+			email IS NOT NULL AND email_verified_at IS NOT NULL,
+			ARRAY(SELECT provider FROM social_accounts WHERE user_id = users.id ORDER BY id),
+			(SELECT COUNT(*) FROM webauthn_credentials WHERE user_id = users.id)
+		FROM users WHERE id = @user_id
+	`
+
+	var methods domain.LoginMethods
+	err := db.QueryRow(ctx, query, pgx.NamedArgs{"user_id": userID}).Scan(
+		&methods.Password,
+		&methods.EmailVerified,
+		&methods.Providers,
+		&methods.Passkeys,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.LoginMethods{}, domain.ErrUserNotFound
+		}
+		return domain.LoginMethods{}, err
+	}
+
+	return methods, nil
+}
+
+func (r *PostgresUserRepository) GetSocialAccountByProviderUserID(provider, providerUserID string) (domain.SocialAccount, error) {
+	query := `
+		SELECT id, provider, provider_user_id, user_id, created_at, updated_at FROM social_accounts
+		WHERE provider = @provider AND provider_user_id = @provider_user_id
 	`
 
 	args := pgx.NamedArgs{
+		"provider":         provider,
 		"provider_user_id": providerUserID,
 	}
 
 	var socialAccount domain.SocialAccount
 
-	err := r.conn.QueryRow(context.Background(), query, args).Scan(
+	err := r.db.QueryRow(context.Background(), query, args).Scan(
 		&socialAccount.ID,
 		&socialAccount.Provider,
 		&socialAccount.ProviderUserID,
@@ -176,9 +301,13 @@ func (r *PostgresUserRepository) GetSocialAccountByProviderUserID(providerUserID
 	return socialAccount, nil
 }
 
+// UpdateSocialAccountUserID links an unlinked social account to the user. It
+// fails with ErrSocialAccountAlreadyLinked when the account was linked in the
+// meantime, and never moves an account from one user to another.
 func (r *PostgresUserRepository) UpdateSocialAccountUserID(socialAccountID, userID int64) error {
 	query := `
-		UPDATE social_accounts SET user_id = @user_id, updated_at = CURRENT_TIMESTAMP WHERE id = @social_account_id
+		UPDATE social_accounts SET user_id = @user_id, updated_at = CURRENT_TIMESTAMP
+		WHERE id = @social_account_id AND user_id IS NULL
 	`
 
 	args := pgx.NamedArgs{
@@ -186,20 +315,43 @@ func (r *PostgresUserRepository) UpdateSocialAccountUserID(socialAccountID, user
 		"user_id":           userID,
 	}
 
-	cmdTag, err := r.conn.Exec(context.Background(), query, args)
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
 	if err != nil {
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("no social account updated (account ID: %v, user ID: %v)", socialAccountID, userID)
+		return domain.ErrSocialAccountAlreadyLinked
 	}
 
 	return nil
 }
 
-func (r *PostgresUserRepository) UpdateUser(usreID int64, user domain.User) error {
-	return nil
+// UpdateUser saves the profile fields of the user, provided the row has not
+// been modified since user.UpdatedAt.
+func (r *PostgresUserRepository) UpdateUser(user domain.User) (domain.User, error) {
+	query := `
+		UPDATE users SET name = @name, pending_email = @pending_email, updated_at = CURRENT_TIMESTAMP
+		WHERE id = @user_id AND updated_at = @updated_at
+		RETURNING updated_at
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":       user.ID,
+		"name":          user.Name,
+		"pending_email": user.PendingEmail,
+		"updated_at":    user.UpdatedAt,
+	}
+
+	err := r.db.QueryRow(context.Background(), query, args).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.User{}, domain.ErrUserModified
+		}
+		return domain.User{}, err
+	}
+
+	return user, nil
 }
 
 func (r *PostgresUserRepository) UpdateUserAvatar(userID int64, avatarUrl string) error {
@@ -212,7 +364,7 @@ func (r *PostgresUserRepository) UpdateUserAvatar(userID int64, avatarUrl string
 		"avatar_url": avatarUrl,
 	}
 
-	cmdTag, err := r.conn.Exec(context.Background(), query, args)
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
 	if err != nil {
 		return err
 	}
@@ -224,7 +376,31 @@ func (r *PostgresUserRepository) UpdateUserAvatar(userID int64, avatarUrl string
 	return nil
 }
 
+// UnlinkSocialAccount unlinks the user's accounts of the provider. It fails
+// with ErrLastLoginMethod when they are the last way left for the user to sign
+// in.
 func (r *PostgresUserRepository) UnlinkSocialAccount(userID int64, provider string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	methods, err := lockLoginMethods(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	remaining := methods.WithoutProvider(provider)
+	if len(remaining.Providers) == len(methods.Providers) {
+		return domain.ErrSocialAccountAlreadyUnlinked
+	}
+	if remaining.Count() == 0 {
+		return domain.ErrLastLoginMethod
+	}
+
 	query := `
 		UPDATE social_accounts SET user_id = null, updated_at = CURRENT_TIMESTAMP WHERE user_id = @user_id AND provider = @provider
 	`
@@ -234,14 +410,233 @@ func (r *PostgresUserRepository) UnlinkSocialAccount(userID int64, provider stri
 		"provider": provider,
 	}
 
-	cmdTag, err := r.conn.Exec(context.Background(), query, args)
+	if _, err := tx.Exec(ctx, query, args); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// MarkEmailVerified verifies the user's current email, or promotes a pending
+// email to the current one once it has been verified.
+func (r *PostgresUserRepository) MarkEmailVerified(userID int64, email string) error {
+	query := `
+		UPDATE users SET email = @email, pending_email = NULL, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = @user_id AND (email = @email OR pending_email = @email)
+	`
+
+	args := pgx.NamedArgs{
+		"user_id": userID,
+		"email":   email,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrDuplicateEmail
+		}
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return domain.ErrSocialAccountAlreadyUnlinked
+		return domain.ErrUserNotFound
 	}
 
 	return nil
 }
+
+func (r *PostgresUserRepository) UpdateUserPassword(userID int64, password string) error {
+	query := `
+		UPDATE users SET password = @password, updated_at = CURRENT_TIMESTAMP WHERE id = @user_id
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":  userID,
+		"password": password,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// RehashUserPassword replaces the stored hash of an unchanged password. It
+// leaves updated_at alone since the user did not change anything, and does
+// nothing if the password was changed in the meantime.
+func (r *PostgresUserRepository) RehashUserPassword(userID int64, currentHash, newHash string) error {
+	query := `
+		UPDATE users SET password = @new_hash WHERE id = @user_id AND password = @current_hash
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":      userID,
+		"current_hash": currentHash,
+		"new_hash":     newHash,
+	}
+
+	_, err := r.db.Exec(context.Background(), query, args)
+	return err
+}
+
+// RevokeUserSessions signs out every session authenticated before revokedAt
+// and removes them from the session index.
+func (r *PostgresUserRepository) RevokeUserSessions(userID int64, revokedAt time.Time) error {
+	query := `
+		WITH revoked AS (
+			UPDATE users SET sessions_revoked_at = @revoked_at WHERE id = @user_id RETURNING id
+		), ended AS (
+			DELETE FROM user_sessions WHERE user_id IN (SELECT id FROM revoked) AND created_at < @revoked_at
+		)
+		SELECT COUNT(*) FROM revoked
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":    userID,
+		"revoked_at": revokedAt,
+	}
+
+	var revoked int
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&revoked); err != nil {
+		return err
+	}
+
+	if revoked == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetSessionRevocation reads only the columns the session guard needs on every
+// request, without the joins of GetUser.
+func (r *PostgresUserRepository) GetSessionRevocation(userID int64) (domain.SessionRevocation, error) {
+	query := `
+		SELECT sessions_revoked_at, deleted_at FROM users WHERE id = @user_id
+	`
+
+	var revocation domain.SessionRevocation
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"user_id": userID}).Scan(
+		&revocation.SessionsRevokedAt,
+		&revocation.DeletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.SessionRevocation{}, domain.ErrUserNotFound
+		}
+		return domain.SessionRevocation{}, err
+	}
+
+	return revocation, nil
+}
+
+// SoftDeleteUser marks the user deleted, revokes their sessions and removes
+// them from the session index.
+func (r *PostgresUserRepository) SoftDeleteUser(userID int64, deletedAt time.Time) error {
+	query := `
+		WITH deleted AS (
+			UPDATE users SET deleted_at = @deleted_at, sessions_revoked_at = @deleted_at, updated_at = CURRENT_TIMESTAMP
+			WHERE id = @user_id AND deleted_at IS NULL
+			RETURNING id
+		), ended AS (
+			DELETE FROM user_sessions WHERE user_id IN (SELECT id FROM deleted)
+		)
+		SELECT COUNT(*) FROM deleted
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":    userID,
+		"deleted_at": deletedAt,
+	}
+
+	var deleted int
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&deleted); err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) RestoreUser(userID int64) error {
+	query := `
+		UPDATE users SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = @user_id
+	`
+
+	cmdTag, err := r.db.Exec(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) GetUsersDeletedBefore(before time.Time) ([]domain.User, error) {
+	query := `
+		SELECT id, avatar, deleted_at FROM users WHERE deleted_at IS NOT NULL AND deleted_at < @before
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"before": before})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []domain.User{}
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Avatar, &user.DeletedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// PurgeUser permanently deletes a user soft-deleted before deletedBefore
+// together with their social accounts. A user restored since it was listed is
+// left alone and reported as not found.
+func (r *PostgresUserRepository) PurgeUser(userID int64, deletedBefore time.Time) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	args := pgx.NamedArgs{
+		"user_id":        userID,
+		"deleted_before": deletedBefore,
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM social_accounts WHERE user_id = @user_id`, args); err != nil {
+		return err
+	}
+
+	cmdTag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = @user_id AND deleted_at < @deleted_before`, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return tx.Commit(ctx)
+}
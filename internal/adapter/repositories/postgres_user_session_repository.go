@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresUserSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresUserSessionRepository(db *pgxpool.Pool) *PostgresUserSessionRepository {
+	return &PostgresUserSessionRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresUserSessionRepository) CreateUserSession(session domain.UserSession) (domain.UserSession, error) {
+	query := `
+		INSERT INTO user_sessions (id, user_id, device, ip_address, user_agent)
+		VALUES (@id, @user_id, @device, @ip_address, @user_agent)
+		RETURNING created_at, last_seen_at, remembered_until
+	`
+
+	args := pgx.NamedArgs{
+		"id":         session.ID,
+		"user_id":    session.UserID,
+		"device":     session.Device,
+		"ip_address": session.IPAddress,
+		"user_agent": session.UserAgent,
+	}
+
+	if err := r.db.QueryRow(context.Background(), query, args).Scan(&session.CreatedAt, &session.LastSeenAt, &session.RememberedUntil); err != nil {
+		return domain.UserSession{}, err
+	}
+
+	return session, nil
+}
+
+func (r *PostgresUserSessionRepository) GetUserSession(userID int64, sessionID string) (domain.UserSession, error) {
+	query := `
+		SELECT id, user_id, device, ip_address, user_agent, created_at, last_seen_at, remembered_until
+		FROM user_sessions WHERE id = @id AND user_id = @user_id
+	`
+
+	args := pgx.NamedArgs{
+		"id":      sessionID,
+		"user_id": userID,
+	}
+
+	session, err := scanUserSession(r.db.QueryRow(context.Background(), query, args))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.UserSession{}, domain.ErrSessionNotFound
+		}
+		return domain.UserSession{}, err
+	}
+
+	return session, nil
+}
+
+func (r *PostgresUserSessionRepository) ListUserSessions(userID int64) ([]domain.UserSession, error) {
+	query := `
+		SELECT id, user_id, device, ip_address, user_agent, created_at, last_seen_at, remembered_until
+		FROM user_sessions WHERE user_id = @user_id ORDER BY last_seen_at DESC
+	`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []domain.UserSession{}
+	for rows.Next() {
+		session, err := scanUserSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetRememberedUserSession returns the session a remember-me token was issued
+// for, as long as the token has not expired.
+func (r *PostgresUserSessionRepository) GetRememberedUserSession(tokenHash string) (domain.UserSession, error) {
+	query := `
+		SELECT id, user_id, device, ip_address, user_agent, created_at, last_seen_at, remembered_until
+		FROM user_sessions WHERE remember_token_hash = @token_hash AND remembered_until > NOW()
+	`
+
+	session, err := scanUserSession(r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"token_hash": tokenHash}))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.UserSession{}, domain.ErrSessionNotFound
+		}
+		return domain.UserSession{}, err
+	}
+
+	return session, nil
+}
+
+// GetUserSessionByRetiredRememberToken returns the session a remember-me token
+// was issued for before it was rotated, and when it was rotated.
+func (r *PostgresUserSessionRepository) GetUserSessionByRetiredRememberToken(tokenHash string) (domain.UserSession, time.Time, error) {
+	query := `
+		SELECT s.id, s.user_id, s.device, s.ip_address, s.user_agent, s.created_at, s.last_seen_at, s.remembered_until,
+		       t.retired_at
+		FROM retired_remember_tokens t JOIN user_sessions s ON s.id = t.session_id
+		WHERE t.token_hash = @token_hash
+	`
+
+	var (
+		session   domain.UserSession
+		retiredAt time.Time
+	)
+	err := r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"token_hash": tokenHash}).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RememberedUntil,
+		&retiredAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.UserSession{}, time.Time{}, domain.ErrSessionNotFound
+		}
+		return domain.UserSession{}, time.Time{}, err
+	}
+
+	return session, retiredAt, nil
+}
+
+func (r *PostgresUserSessionRepository) TouchUserSession(sessionID, ipAddress string, lastSeenAt time.Time) error {
+	query := `
+		UPDATE user_sessions SET ip_address = @ip_address, last_seen_at = @last_seen_at WHERE id = @id
+	`
+
+	args := pgx.NamedArgs{
+		"id":           sessionID,
+		"ip_address":   ipAddress,
+		"last_seen_at": lastSeenAt,
+	}
+
+	_, err := r.db.Exec(context.Background(), query, args)
+	return err
+}
+
+func (r *PostgresUserSessionRepository) RememberUserSession(sessionID, tokenHash string, rememberedUntil time.Time) error {
+	query := `
+		UPDATE user_sessions
+		SET remember_token_hash = @token_hash, remembered_until = @remembered_until
+		WHERE id = @id
+	`
+
+	args := pgx.NamedArgs{
+		"id":               sessionID,
+		"token_hash":       tokenHash,
+		"remembered_until": rememberedUntil,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// RotateRememberToken replaces the remember-me token of the session and
+// retires the old one, so that its reuse can be recognized. It fails with
+// ErrSessionNotFound when the old token is no longer the current one.
+func (r *PostgresUserSessionRepository) RotateRememberToken(sessionID, oldTokenHash, newTokenHash string) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	args := pgx.NamedArgs{
+		"id":             sessionID,
+		"old_token_hash": oldTokenHash,
+		"new_token_hash": newTokenHash,
+	}
+
+	query := `
+		UPDATE user_sessions SET remember_token_hash = @new_token_hash
+		WHERE id = @id AND remember_token_hash = @old_token_hash AND remembered_until > NOW()
+	`
+
+	cmdTag, err := tx.Exec(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	query = `
+		INSERT INTO retired_remember_tokens (token_hash, session_id) VALUES (@old_token_hash, @id)
+	`
+
+	if _, err := tx.Exec(ctx, query, args); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PostgresUserSessionRepository) DeleteUserSession(userID int64, sessionID string) error {
+	query := `
+		DELETE FROM user_sessions WHERE id = @id AND user_id = @user_id
+	`
+
+	args := pgx.NamedArgs{
+		"id":      sessionID,
+		"user_id": userID,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresUserSessionRepository) DeleteOtherUserSessions(userID int64, keepSessionID string) error {
+	query := `
+		DELETE FROM user_sessions WHERE user_id = @user_id AND id <> @id
+	`
+
+	args := pgx.NamedArgs{
+		"id":      keepSessionID,
+		"user_id": userID,
+	}
+
+	_, err := r.db.Exec(context.Background(), query, args)
+	return err
+}
+
+func scanUserSession(row pgx.Row) (domain.UserSession, error) {
+	var session domain.UserSession
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RememberedUntil,
+	)
+
+	return session, err
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const webAuthnCredentialColumns = `
+	id, user_id, name, credential_id, public_key, attestation_type, transports, aaguid,
+	sign_count, backup_eligible, backup_state, last_used_at, created_at
+`
+
+type PostgresWebAuthnCredentialRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresWebAuthnCredentialRepository(db *pgxpool.Pool) *PostgresWebAuthnCredentialRepository {
+	return &PostgresWebAuthnCredentialRepository{
+		db: db,
+	}
+}
+
+func (r *PostgresWebAuthnCredentialRepository) CreateWebAuthnCredential(credential domain.WebAuthnCredential) (domain.WebAuthnCredential, error) {
+	query := `
+		INSERT INTO webauthn_credentials (
+			user_id, name, credential_id, public_key, attestation_type, transports, aaguid,
+			sign_count, backup_eligible, backup_state
+		)
+		VALUES (
+			@user_id, @name, @credential_id, @public_key, @attestation_type, @transports, @aaguid,
+			@sign_count, @backup_eligible, @backup_state
+		)
+		RETURNING id, created_at
+	`
+
+	args := pgx.NamedArgs{
+		"user_id":          credential.UserID,
+		"name":             credential.Name,
+		"credential_id":    credential.CredentialID,
+		"public_key":       credential.PublicKey,
+		"attestation_type": credential.AttestationType,
+		"transports":       credential.Transports,
+		"aaguid":           credential.AAGUID,
+		"sign_count":       int64(credential.SignCount),
+		"backup_eligible":  credential.BackupEligible,
+		"backup_state":     credential.BackupState,
+	}
+
+	err := r.db.QueryRow(context.Background(), query, args).Scan(&credential.ID, &credential.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.WebAuthnCredential{}, fmt.Errorf("%w: credential is already registered", domain.ErrWebAuthnFailed)
+		}
+		return domain.WebAuthnCredential{}, err
+	}
+
+	return credential, nil
+}
+
+func (r *PostgresWebAuthnCredentialRepository) GetWebAuthnCredentialByCredentialID(credentialID []byte) (domain.WebAuthnCredential, error) {
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE credential_id = @credential_id`
+
+	credential, err := scanWebAuthnCredential(r.db.QueryRow(context.Background(), query, pgx.NamedArgs{"credential_id": credentialID}))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.WebAuthnCredential{}, domain.ErrWebAuthnCredentialNotFound
+		}
+		return domain.WebAuthnCredential{}, err
+	}
+
+	return credential, nil
+}
+
+func (r *PostgresWebAuthnCredentialRepository) ListWebAuthnCredentials(userID int64) ([]domain.WebAuthnCredential, error) {
+	query := `SELECT ` + webAuthnCredentialColumns + ` FROM webauthn_credentials WHERE user_id = @user_id ORDER BY created_at`
+
+	rows, err := r.db.Query(context.Background(), query, pgx.NamedArgs{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []domain.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+// UpdateWebAuthnCredentialUsage records the state reported by the
+// authenticator on a successful assertion.
+func (r *PostgresWebAuthnCredentialRepository) UpdateWebAuthnCredentialUsage(id int64, signCount uint32, backupState bool) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = @sign_count, backup_state = @backup_state, last_used_at = CURRENT_TIMESTAMP
+		WHERE id = @id
+	`
+
+	args := pgx.NamedArgs{
+		"id":           id,
+		"sign_count":   int64(signCount),
+		"backup_state": backupState,
+	}
+
+	_, err := r.db.Exec(context.Background(), query, args)
+	return err
+}
+
+func (r *PostgresWebAuthnCredentialRepository) RenameWebAuthnCredential(userID, id int64, name string) error {
+	query := `
+		UPDATE webauthn_credentials SET name = @name WHERE id = @id AND user_id = @user_id
+	`
+
+	args := pgx.NamedArgs{
+		"id":      id,
+		"user_id": userID,
+		"name":    name,
+	}
+
+	cmdTag, err := r.db.Exec(context.Background(), query, args)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+// DeleteWebAuthnCredential deletes one of the user's passkeys. It fails with
+// ErrLastLoginMethod when it is the last way left for the user to sign in.
+func (r *PostgresWebAuthnCredentialRepository) DeleteWebAuthnCredential(userID, id int64) error {
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	methods, err := lockLoginMethods(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if methods.Passkeys > 0 && methods.WithoutPasskey().Count() == 0 {
+		return domain.ErrLastLoginMethod
+	}
+
+	query := `
+		DELETE FROM webauthn_credentials WHERE id = @id AND user_id = @user_id
+	`
+
+	cmdTag, err := tx.Exec(ctx, query, pgx.NamedArgs{"id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return domain.ErrWebAuthnCredentialNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+func scanWebAuthnCredential(row pgx.Row) (domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	var signCount int64
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.CredentialID,
+		&credential.PublicKey,
+		&credential.AttestationType,
+		&credential.Transports,
+		&credential.AAGUID,
+		&signCount,
+		&credential.BackupEligible,
+		&credential.BackupState,
+		&credential.LastUsedAt,
+		&credential.CreatedAt,
+	)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	credential.SignCount = uint32(signCount)
+	return credential, nil
+}
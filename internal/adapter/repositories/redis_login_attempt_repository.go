@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const loginAttemptKeyPrefix = "login_attempts:"
+
+type RedisLoginAttemptRepository struct {
+	pool *redis.Pool
+}
+
+func NewRedisLoginAttemptRepository(pool *redis.Pool) *RedisLoginAttemptRepository {
+	return &RedisLoginAttemptRepository{
+		pool: pool,
+	}
+}
+
+// RecordFailure counts a failed attempt and returns the failures within the
+// window. The window restarts with every failure.
+func (r *RedisLoginAttemptRepository) RecordFailure(key string, window time.Duration) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	countKey, lastKey := loginAttemptKeyPrefix+key+":count", loginAttemptKeyPrefix+key+":last"
+	ttl := window.Milliseconds()
+
+	conn.Send("MULTI")
+	conn.Send("INCR", countKey)
+	conn.Send("PEXPIRE", countKey, ttl)
+	conn.Send("SET", lastKey, time.Now().UnixMilli(), "PX", ttl)
+
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+
+	return redis.Int(replies[0], nil)
+}
+
+func (r *RedisLoginAttemptRepository) GetFailures(key string) (int, *time.Time, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do("MGET", loginAttemptKeyPrefix+key+":count", loginAttemptKeyPrefix+key+":last"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	count, err := redis.Int(values[0], nil)
+	if errors.Is(err, redis.ErrNil) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	last, err := redis.Int64(values[1], nil)
+	if errors.Is(err, redis.ErrNil) {
+		return count, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	lastFailureAt := time.UnixMilli(last)
+	return count, &lastFailureAt, nil
+}
+
+func (r *RedisLoginAttemptRepository) ResetFailures(key string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", loginAttemptKeyPrefix+key+":count", loginAttemptKeyPrefix+key+":last")
+	return err
+}
+
+func (r *RedisLoginAttemptRepository) Lock(key string, until time.Time) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", loginAttemptKeyPrefix+key+":locked", until.UnixMilli(), "PX", time.Until(until).Milliseconds())
+	return err
+}
+
+func (r *RedisLoginAttemptRepository) LockedUntil(key string) (*time.Time, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	until, err := redis.Int64(conn.Do("GET", loginAttemptKeyPrefix+key+":locked"))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := time.UnixMilli(until)
+	return &lockedUntil, nil
+}
+
+// Unlock lifts the lockout and forgets the failures that caused it.
+func (r *RedisLoginAttemptRepository) Unlock(key string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL",
+		loginAttemptKeyPrefix+key+":locked",
+		loginAttemptKeyPrefix+key+":count",
+		loginAttemptKeyPrefix+key+":last",
+	)
+	return err
+}
@@ -0,0 +1,101 @@
+package sessionstores
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	gorillasessions "github.com/gorilla/sessions"
+)
+
+type memorySession struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// MemoryStore keeps sessions in the process. Sessions do not survive a
+// restart and are not shared between instances, so it is meant for tests and
+// single-node development.
+type MemoryStore struct {
+	serverStore
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+func NewMemoryStore(keyPairs ...[]byte) *MemoryStore {
+	return &MemoryStore{
+		serverStore: newServerStore(keyPairs...),
+		sessions:    make(map[string]memorySession),
+	}
+}
+
+func (s *MemoryStore) Get(r *http.Request, name string) (*gorillasessions.Session, error) {
+	return gorillasessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *MemoryStore) New(r *http.Request, name string) (*gorillasessions.Session, error) {
+	session := s.newSession(s, r, name)
+	if session.ID == "" {
+		return session, nil
+	}
+
+	s.mu.Lock()
+	stored, ok := s.sessions[session.ID]
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(stored.expiresAt) {
+		session.ID = ""
+		return session, nil
+	}
+
+	values, err := decodeValues(stored.data)
+	if err != nil {
+		session.ID = ""
+		return session, nil
+	}
+
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *MemoryStore) Save(r *http.Request, w http.ResponseWriter, session *gorillasessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		s.mu.Lock()
+		delete(s.sessions, session.ID)
+		s.mu.Unlock()
+
+		return s.setCookie(w, session)
+	}
+
+	data, err := encodeValues(session.Values)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if session.ID == "" {
+		session.ID = newSessionID()
+
+		// Expired sessions are cleaned up as new ones come in.
+		s.purge()
+	}
+	s.sessions[session.ID] = memorySession{
+		data:      data,
+		expiresAt: time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+	s.mu.Unlock()
+
+	return s.setCookie(w, session)
+}
+
+// purge drops expired sessions. The caller holds the lock.
+func (s *MemoryStore) purge() {
+	now := time.Now()
+	for id, stored := range s.sessions {
+		if now.After(stored.expiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sessionstores
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	gorillasessions "github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps sessions in the http_sessions table, for deployments
+// that run without Redis.
+type PostgresStore struct {
+	serverStore
+
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool, keyPairs ...[]byte) *PostgresStore {
+	return &PostgresStore{
+		serverStore: newServerStore(keyPairs...),
+		db:          db,
+	}
+}
+
+func (s *PostgresStore) Get(r *http.Request, name string) (*gorillasessions.Session, error) {
+	return gorillasessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *PostgresStore) New(r *http.Request, name string) (*gorillasessions.Session, error) {
+	session := s.newSession(s, r, name)
+	if session.ID == "" {
+		return session, nil
+	}
+
+	query := `SELECT data FROM http_sessions WHERE id = @id AND expires_at > NOW()`
+
+	var data []byte
+	if err := s.db.QueryRow(context.Background(), query, pgx.NamedArgs{"id": session.ID}).Scan(&data); err != nil {
+		session.ID = ""
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session, nil
+		}
+		return session, err
+	}
+
+	values, err := decodeValues(data)
+	if err != nil {
+		session.ID = ""
+		return session, nil
+	}
+
+	session.Values = values
+	session.IsNew = false
+	return session, nil
+}
+
+func (s *PostgresStore) Save(r *http.Request, w http.ResponseWriter, session *gorillasessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			query := `DELETE FROM http_sessions WHERE id = @id`
+			if _, err := s.db.Exec(context.Background(), query, pgx.NamedArgs{"id": session.ID}); err != nil {
+				return err
+			}
+		}
+
+		return s.setCookie(w, session)
+	}
+
+	data, err := encodeValues(session.Values)
+	if err != nil {
+		return err
+	}
+
+	if session.ID == "" {
+		session.ID = newSessionID()
+
+		// Expired sessions are cleaned up as new ones come in.
+		if _, err := s.db.Exec(context.Background(), `DELETE FROM http_sessions WHERE expires_at <= NOW()`); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO http_sessions (id, data, expires_at)
+		VALUES (@id, @data, @expires_at)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at
+	`
+
+	args := pgx.NamedArgs{
+		"id":         session.ID,
+		"data":       data,
+		"expires_at": time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+
+	if _, err := s.db.Exec(context.Background(), query, args); err != nil {
+		return err
+	}
+
+	return s.setCookie(w, session)
+}
@@ -0,0 +1,137 @@
+package sessionstores
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/database"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-contrib/sessions/redis"
+	"github.com/gorilla/securecookie"
+	gorillasessions "github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultDriver = "redis"
+
+// NewSessionStore returns the session store selected by SESSION_STORE. A
+// missing secret or an unknown store fails at startup rather than on the
+// first request. Redis is only connected to when it is selected.
+func NewSessionStore(db *pgxpool.Pool) (sessions.Store, func(), error) {
+	secret := config.AppConfig.SessionSecret
+	if secret == "" {
+		// Sessions used to be kept in Redis only, under the Redis secret.
+		secret = config.AppConfig.RedisSecret
+	}
+	if secret == "" {
+		return nil, nil, errors.New("no session secret configured")
+	}
+	key := []byte(secret)
+
+	driver := config.AppConfig.SessionStore
+	if driver == "" {
+		driver = defaultDriver
+	}
+
+	switch driver {
+	case "redis":
+		pool, err := database.NewRedisPool()
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := redis.NewStoreWithPool(pool, key)
+		if err != nil {
+			pool.Close()
+			return nil, nil, fmt.Errorf("failed to create redis session store: %w", err)
+		}
+		return store, func() { pool.Close() }, nil
+	case "postgres":
+		return NewPostgresStore(db, key), func() {}, nil
+	case "cookie":
+		return cookie.NewStore(key), func() {}, nil
+	case "memory":
+		return NewMemoryStore(key), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown session store: %s", driver)
+	}
+}
+
+// serverStore holds what the stores keeping the session values on the server
+// share: only the signed session ID is sent in the cookie.
+type serverStore struct {
+	codecs  []securecookie.Codec
+	options *gorillasessions.Options
+}
+
+func newServerStore(keyPairs ...[]byte) serverStore {
+	return serverStore{
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		options: &gorillasessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+}
+
+func (s *serverStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+// newSession returns an empty session carrying the ID from the request
+// cookie, if it has a valid one.
+func (s *serverStore) newSession(store gorillasessions.Store, r *http.Request, name string) *gorillasessions.Session {
+	session := gorillasessions.NewSession(store, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	if c, err := r.Cookie(name); err == nil {
+		if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecs...); err != nil {
+			session.ID = ""
+		}
+	}
+
+	return session
+}
+
+func (s *serverStore) setCookie(w http.ResponseWriter, session *gorillasessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		http.SetCookie(w, gorillasessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, gorillasessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func newSessionID() string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+}
+
+func encodeValues(values map[interface{}]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValues(data []byte) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
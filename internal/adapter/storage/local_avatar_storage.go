@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+const uploadDir = "./uploads"
+
+// LocalAvatarStorage manages avatars uploaded to the local uploads directory.
+type LocalAvatarStorage struct {
+	baseUrl string
+	dir     string
+}
+
+func NewLocalAvatarStorage() *LocalAvatarStorage {
+	return &LocalAvatarStorage{
+		baseUrl: config.AppConfig.UploadBaseUrl + "/uploads/",
+		dir:     uploadDir,
+	}
+}
+
+// DeleteAvatar removes the uploaded file behind avatarUrl. Avatars hosted
+// elsewhere, such as social provider pictures, are left alone.
+func (s *LocalAvatarStorage) DeleteAvatar(avatarUrl string) error {
+	filename, ok := strings.CutPrefix(avatarUrl, s.baseUrl)
+	if !ok || filename == "" || filename != filepath.Base(filename) {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(s.dir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
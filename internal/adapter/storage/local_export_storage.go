@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+const defaultExportDir = "./exports"
+
+// LocalExportStorage writes data export archives as zip files to a local
+// directory.
+type LocalExportStorage struct {
+	dir string
+}
+
+func NewLocalExportStorage() *LocalExportStorage {
+	dir := config.AppConfig.DataExportDir
+	if dir == "" {
+		dir = defaultExportDir
+	}
+
+	return &LocalExportStorage{
+		dir: dir,
+	}
+}
+
+func (s *LocalExportStorage) SaveExportArchive(export domain.DataExport, files map[string][]byte) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	// Write into a temporary file first so a download never sees a partial archive.
+	path := filepath.Join(s.dir, fmt.Sprintf("%d-%s.zip", export.UserID, export.ID))
+	tmp, err := os.CreateTemp(s.dir, "export-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	archive := zip.NewWriter(tmp)
+	for _, name := range names {
+		w, err := archive.Create(name)
+		if err != nil {
+			tmp.Close()
+			return "", err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// DeleteExportArchives removes every archive generated for the user.
+func (s *LocalExportStorage) DeleteExportArchives(userID int64) error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, fmt.Sprintf("%d-*.zip", userID)))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-gonic/gin"
+)
+
+// App is the server: the HTTP router and the background account purger,
+// sharing one database pool.
+type App struct {
+	Router *gin.Engine
+	Purger in.AccountPurgeUsecase
+}
+
+func NewApp(router *gin.Engine, purger in.AccountPurgeUsecase) *App {
+	return &App{
+		Router: router,
+		Purger: purger,
+	}
+}
@@ -0,0 +1,68 @@
+package application
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+)
+
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// AccountPurgeService permanently removes users whose deletion grace period
+// has passed.
+type AccountPurgeService struct {
+	userRepo out.UserRepository
+	avatars  out.AvatarStorage
+	exports  out.ExportStorage
+}
+
+func NewAccountPurgeService(userRepo out.UserRepository, avatars out.AvatarStorage, exports out.ExportStorage) *AccountPurgeService {
+	return &AccountPurgeService{
+		userRepo: userRepo,
+		avatars:  avatars,
+		exports:  exports,
+	}
+}
+
+// PurgeDeletedUsers deletes the users, their social accounts, their uploaded
+// avatars and data exports, and returns how many users were purged. A user
+// that fails to purge is logged and left for the next run, so that it does not
+// hold back the rest of the batch.
+func (s *AccountPurgeService) PurgeDeletedUsers() (int, error) {
+	cutoff := time.Now().Add(-deletionGracePeriod())
+	users, err := s.userRepo.GetUsersDeletedBefore(cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find deleted users: %w", err)
+	}
+
+	purged := 0
+	for _, user := range users {
+		if err := s.userRepo.PurgeUser(user.ID, cutoff); err != nil {
+			log.Printf("failed to purge user %d: %v", user.ID, err)
+			continue
+		}
+		purged++
+
+		// The user is gone either way; leftover files are only logged.
+		if user.Avatar != nil {
+			if err := s.avatars.DeleteAvatar(*user.Avatar); err != nil {
+				log.Printf("failed to delete avatar of purged user %d: %v", user.ID, err)
+			}
+		}
+		if err := s.exports.DeleteExportArchives(user.ID); err != nil {
+			log.Printf("failed to delete data exports of purged user %d: %v", user.ID, err)
+		}
+	}
+
+	return purged, nil
+}
+
+func deletionGracePeriod() time.Duration {
+	if config.AppConfig.AccountDeletionGracePeriod > 0 {
+		return config.AppConfig.AccountDeletionGracePeriod
+	}
+	return defaultDeletionGracePeriod
+}
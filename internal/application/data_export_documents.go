@@ -0,0 +1,232 @@
+package application
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+// The exported documents spell out every stored field, independent of the
+// JSON the API returns for the same entities.
+
+type exportedUser struct {
+	ID                int64      `json:"id"`
+	Email             *string    `json:"email"`
+	PendingEmail      *string    `json:"pending_email"`
+	Name              string     `json:"name"`
+	Avatar            *string    `json:"avatar"`
+	HasPassword       bool       `json:"has_password"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	SessionsRevokedAt *time.Time `json:"sessions_revoked_at"`
+	DeletedAt         *time.Time `json:"deleted_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+type exportedSocialAccount struct {
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	Email          *string   `json:"email"`
+	EmailVerified  bool      `json:"email_verified"`
+	Name           *string   `json:"name"`
+	Avatar         *string   `json:"avatar"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type exportedEmailVerification struct {
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Reset token hashes are credentials, not personal data, and are left out.
+type exportedPasswordReset struct {
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Remember-me token hashes are credentials and are left out.
+type exportedUserSession struct {
+	Device          string     `json:"device"`
+	IPAddress       string     `json:"ip_address"`
+	UserAgent       string     `json:"user_agent"`
+	RememberedUntil *time.Time `json:"remembered_until"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+}
+
+// TOTP secrets and recovery code hashes are credentials and are left out.
+type exportedMFA struct {
+	TOTPEnrolledAt *time.Time             `json:"totp_enrolled_at"`
+	TOTPEnabledAt  *time.Time             `json:"totp_enabled_at"`
+	RecoveryCodes  []exportedRecoveryCode `json:"recovery_codes"`
+}
+
+type exportedRecoveryCode struct {
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Credential IDs and public keys are authenticator material and are left out.
+type exportedPasskey struct {
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackupState    bool       `json:"backup_state"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Device hashes bind a link to a browser and are left out.
+type exportedMagicLink struct {
+	Email     string     `json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Unlock token hashes are credentials and are left out.
+type exportedAccountUnlockToken struct {
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// exportedOmission tells in the manifest what is not exported and why.
+type exportedOmission struct {
+	Data   string `json:"data"`
+	Reason string `json:"reason"`
+}
+
+func exportedUserFrom(user domain.User) exportedUser {
+	return exportedUser{
+		ID:                user.ID,
+		Email:             user.Email,
+		PendingEmail:      user.PendingEmail,
+		Name:              user.Name,
+		Avatar:            user.Avatar,
+		HasPassword:       user.Password != "",
+		EmailVerifiedAt:   user.EmailVerifiedAt,
+		SessionsRevokedAt: user.SessionsRevokedAt,
+		DeletedAt:         user.DeletedAt,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+	}
+}
+
+func exportedSocialAccountsFrom(socialAccounts []domain.SocialAccount) []exportedSocialAccount {
+	exported := make([]exportedSocialAccount, 0, len(socialAccounts))
+	for _, account := range socialAccounts {
+		exported = append(exported, exportedSocialAccount{
+			Provider:       account.Provider,
+			ProviderUserID: account.ProviderUserID,
+			Email:          account.Email,
+			EmailVerified:  account.EmailVerified,
+			Name:           account.Name,
+			Avatar:         account.Avatar,
+			CreatedAt:      account.CreatedAt,
+			UpdatedAt:      account.UpdatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedEmailVerificationsFrom(verifications []domain.EmailVerification) []exportedEmailVerification {
+	exported := make([]exportedEmailVerification, 0, len(verifications))
+	for _, verification := range verifications {
+		exported = append(exported, exportedEmailVerification{
+			Email:     verification.Email,
+			ExpiresAt: verification.ExpiresAt,
+			UsedAt:    verification.UsedAt,
+			CreatedAt: verification.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedPasswordResetsFrom(resets []domain.PasswordReset) []exportedPasswordReset {
+	exported := make([]exportedPasswordReset, 0, len(resets))
+	for _, reset := range resets {
+		exported = append(exported, exportedPasswordReset{
+			ExpiresAt: reset.ExpiresAt,
+			UsedAt:    reset.UsedAt,
+			CreatedAt: reset.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedUserSessionsFrom(userSessions []domain.UserSession) []exportedUserSession {
+	exported := make([]exportedUserSession, 0, len(userSessions))
+	for _, userSession := range userSessions {
+		exported = append(exported, exportedUserSession{
+			Device:          userSession.Device,
+			IPAddress:       userSession.IPAddress,
+			UserAgent:       userSession.UserAgent,
+			RememberedUntil: userSession.RememberedUntil,
+			CreatedAt:       userSession.CreatedAt,
+			LastSeenAt:      userSession.LastSeenAt,
+		})
+	}
+	return exported
+}
+
+func exportedMFAFrom(totp *domain.TOTP, recoveryCodes []domain.RecoveryCode) exportedMFA {
+	exported := exportedMFA{
+		RecoveryCodes: make([]exportedRecoveryCode, 0, len(recoveryCodes)),
+	}
+	if totp != nil {
+		exported.TOTPEnrolledAt = &totp.CreatedAt
+		exported.TOTPEnabledAt = totp.EnabledAt
+	}
+	for _, code := range recoveryCodes {
+		exported.RecoveryCodes = append(exported.RecoveryCodes, exportedRecoveryCode{
+			UsedAt:    code.UsedAt,
+			CreatedAt: code.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedPasskeysFrom(credentials []domain.WebAuthnCredential) []exportedPasskey {
+	exported := make([]exportedPasskey, 0, len(credentials))
+	for _, credential := range credentials {
+		exported = append(exported, exportedPasskey{
+			Name:           credential.Name,
+			Transports:     credential.Transports,
+			BackupEligible: credential.BackupEligible,
+			BackupState:    credential.BackupState,
+			LastUsedAt:     credential.LastUsedAt,
+			CreatedAt:      credential.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedMagicLinksFrom(links []domain.MagicLink) []exportedMagicLink {
+	exported := make([]exportedMagicLink, 0, len(links))
+	for _, link := range links {
+		exported = append(exported, exportedMagicLink{
+			Email:     link.Email,
+			ExpiresAt: link.ExpiresAt,
+			UsedAt:    link.UsedAt,
+			CreatedAt: link.CreatedAt,
+		})
+	}
+	return exported
+}
+
+func exportedAccountUnlockTokensFrom(tokens []domain.AccountUnlockToken) []exportedAccountUnlockToken {
+	exported := make([]exportedAccountUnlockToken, 0, len(tokens))
+	for _, token := range tokens {
+		exported = append(exported, exportedAccountUnlockToken{
+			ExpiresAt:  token.ExpiresAt,
+			ConsumedAt: token.ConsumedAt,
+			CreatedAt:  token.CreatedAt,
+		})
+	}
+	return exported
+}
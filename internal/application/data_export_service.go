@@ -0,0 +1,332 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const (
+	dataExportPurpose       = "data_export"
+	dataExportFormatVersion = 1
+	defaultDataExportTTL    = 24 * time.Hour
+
+	// Exports still pending after this long are assumed lost, e.g. to a restart.
+	dataExportJobTimeout = time.Hour
+)
+
+// dataExportOmissions is what is stored about the user but not exported.
+var dataExportOmissions = []exportedOmission{
+	{"consents", "Not recorded by this server."},
+	{"audit_events", "Not recorded by this server."},
+	{"login_attempts", "Failed sign-in counters are kept per account and client IP, not with the user, and expire with the lockout window."},
+	{"retired_remember_tokens", "Hashes of replaced remember-me tokens are credentials, kept briefly only to detect token reuse."},
+}
+
+type dataExportClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.StandardClaims
+}
+
+type DataExportService struct {
+	userRepo         out.UserRepository
+	verificationRepo out.EmailVerificationRepository
+	resetRepo        out.PasswordResetRepository
+	sessionRepo      out.UserSessionRepository
+	mfaRepo          out.MFARepository
+	credentialRepo   out.WebAuthnCredentialRepository
+	magicLinkRepo    out.MagicLinkRepository
+	unlockTokenRepo  out.AccountUnlockTokenRepository
+	exportRepo       out.DataExportRepository
+	storage          out.ExportStorage
+}
+
+func NewDataExportService(
+	userRepo out.UserRepository,
+	verificationRepo out.EmailVerificationRepository,
+	resetRepo out.PasswordResetRepository,
+	sessionRepo out.UserSessionRepository,
+	mfaRepo out.MFARepository,
+	credentialRepo out.WebAuthnCredentialRepository,
+	magicLinkRepo out.MagicLinkRepository,
+	unlockTokenRepo out.AccountUnlockTokenRepository,
+	exportRepo out.DataExportRepository,
+	storage out.ExportStorage,
+) *DataExportService {
+	return &DataExportService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		resetRepo:        resetRepo,
+		sessionRepo:      sessionRepo,
+		mfaRepo:          mfaRepo,
+		credentialRepo:   credentialRepo,
+		magicLinkRepo:    magicLinkRepo,
+		unlockTokenRepo:  unlockTokenRepo,
+		exportRepo:       exportRepo,
+		storage:          storage,
+	}
+}
+
+// RequestDataExport returns the user's current export, starting a new one in
+// the background when there is none in progress or ready to download.
+func (s *DataExportService) RequestDataExport(userID int64) (in.DataExportResult, error) {
+	latest, err := s.exportRepo.GetLatestDataExport(userID)
+	switch {
+	case err == nil && latest.Status == domain.DataExportPending && time.Since(latest.CreatedAt) < dataExportJobTimeout:
+		return s.result(latest)
+	case err == nil && latest.Status == domain.DataExportReady && time.Now().Before(*latest.ExpiresAt):
+		return s.result(latest)
+	case err != nil && !errors.Is(err, domain.ErrDataExportNotFound):
+		return in.DataExportResult{}, err
+	}
+
+	export, err := s.exportRepo.CreateDataExport(domain.DataExport{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		Status: domain.DataExportPending,
+	})
+	if err != nil {
+		return in.DataExportResult{}, fmt.Errorf("failed to create data export: %w", err)
+	}
+
+	go s.generate(export)
+
+	return s.result(export)
+}
+
+// OpenDataExport validates a signed download link and returns the path of the
+// archive it points to.
+func (s *DataExportService) OpenDataExport(token string) (string, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", domain.ErrInvalidExportToken, err.Error())
+	}
+
+	export, err := s.exportRepo.GetDataExport(claims.Id)
+	if err != nil {
+		if errors.Is(err, domain.ErrDataExportNotFound) {
+			return "", domain.ErrInvalidExportToken
+		}
+		return "", err
+	}
+
+	if export.Status != domain.DataExportReady || export.FilePath == nil || time.Now().After(*export.ExpiresAt) ||
+		strconv.FormatInt(export.UserID, 10) != claims.Subject {
+		return "", domain.ErrInvalidExportToken
+	}
+
+	return *export.FilePath, nil
+}
+
+func (s *DataExportService) generate(export domain.DataExport) {
+	// A panic would otherwise take down the server and leave the export
+	// pending until it times out.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("data export %s for user %d panicked: %v", export.ID, export.UserID, r)
+			now := time.Now()
+			export.Status = domain.DataExportFailed
+			export.CompletedAt = &now
+			if err := s.exportRepo.UpdateDataExport(export); err != nil {
+				log.Printf("failed to update data export %s: %v", export.ID, err)
+			}
+		}
+	}()
+
+	files, err := s.collect(export.UserID)
+	if err == nil {
+		// Only the latest archive is kept.
+		err = s.storage.DeleteExportArchives(export.UserID)
+	}
+
+	var path string
+	if err == nil {
+		path, err = s.storage.SaveExportArchive(export, files)
+	}
+
+	now := time.Now()
+	export.CompletedAt = &now
+
+	if err != nil {
+		log.Printf("failed to generate data export %s for user %d: %v", export.ID, export.UserID, err)
+		export.Status = domain.DataExportFailed
+	} else {
+		expiresAt := now.Add(s.ttl())
+		export.Status = domain.DataExportReady
+		export.FilePath = &path
+		export.ExpiresAt = &expiresAt
+	}
+
+	if err := s.exportRepo.UpdateDataExport(export); err != nil {
+		log.Printf("failed to update data export %s: %v", export.ID, err)
+	}
+}
+
+// collect gathers everything stored about the user as JSON documents keyed by
+// their file name in the archive.
+func (s *DataExportService) collect(userID int64) (map[string][]byte, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	socialAccounts, err := s.userRepo.ListSocialAccounts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	verifications, err := s.verificationRepo.ListEmailVerifications(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resets, err := s.resetRepo.ListPasswordResets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userSessions, err := s.sessionRepo.ListUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var totp *domain.TOTP
+	enrollment, err := s.mfaRepo.GetTOTP(userID)
+	switch {
+	case err == nil:
+		totp = &enrollment
+	case !errors.Is(err, domain.ErrTOTPNotEnrolled):
+		return nil, err
+	}
+
+	recoveryCodes, err := s.mfaRepo.ListRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepo.ListWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	magicLinks, err := s.magicLinkRepo.ListMagicLinks(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlockTokens, err := s.unlockTokenRepo.ListAccountUnlockTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := map[string]any{
+		"user.json":                  exportedUserFrom(user),
+		"social_accounts.json":       exportedSocialAccountsFrom(socialAccounts),
+		"email_verifications.json":   exportedEmailVerificationsFrom(verifications),
+		"password_resets.json":       exportedPasswordResetsFrom(resets),
+		"sessions.json":              exportedUserSessionsFrom(userSessions),
+		"mfa.json":                   exportedMFAFrom(totp, recoveryCodes),
+		"passkeys.json":              exportedPasskeysFrom(credentials),
+		"magic_links.json":           exportedMagicLinksFrom(magicLinks),
+		"account_unlock_tokens.json": exportedAccountUnlockTokensFrom(unlockTokens),
+	}
+
+	sections := make([]string, 0, len(documents))
+	for name := range documents {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+
+	documents["manifest.json"] = map[string]any{
+		"format_version": dataExportFormatVersion,
+		"user_id":        userID,
+		"generated_at":   time.Now().UTC(),
+		"files":          sections,
+		"not_exported":   dataExportOmissions,
+	}
+
+	files := make(map[string][]byte, len(documents))
+	for name, document := range documents {
+		content, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		files[name] = content
+	}
+
+	return files, nil
+}
+
+func (s *DataExportService) result(export domain.DataExport) (in.DataExportResult, error) {
+	result := in.DataExportResult{Status: export.Status}
+	if export.Status != domain.DataExportReady {
+		return result, nil
+	}
+
+	token, err := s.signToken(export)
+	if err != nil {
+		return in.DataExportResult{}, fmt.Errorf("failed to sign data export token: %w", err)
+	}
+
+	result.DownloadUrl = fmt.Sprintf(
+		"%s/api/user/export/download?token=%s",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		url.QueryEscape(token),
+	)
+	result.ExpiresAt = export.ExpiresAt
+
+	return result, nil
+}
+
+func (s *DataExportService) signToken(export domain.DataExport) (string, error) {
+	claims := dataExportClaims{
+		Purpose: dataExportPurpose,
+		StandardClaims: jwt.StandardClaims{
+			Id:        export.ID,
+			Subject:   strconv.FormatInt(export.UserID, 10),
+			ExpiresAt: export.ExpiresAt.Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JwtSecret))
+}
+
+func (s *DataExportService) parseToken(token string) (dataExportClaims, error) {
+	var claims dataExportClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Method)
+		}
+		return []byte(config.AppConfig.JwtSecret), nil
+	})
+	if err != nil {
+		return dataExportClaims{}, err
+	}
+
+	if claims.Purpose != dataExportPurpose {
+		return dataExportClaims{}, fmt.Errorf("unexpected token purpose: %s", claims.Purpose)
+	}
+
+	return claims, nil
+}
+
+func (s *DataExportService) ttl() time.Duration {
+	if config.AppConfig.DataExportTTL > 0 {
+		return config.AppConfig.DataExportTTL
+	}
+	return defaultDataExportTTL
+}
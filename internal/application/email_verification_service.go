@@ -0,0 +1,237 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const (
+	emailVerificationPurpose        = "email_verification"
+	defaultEmailVerificationTTL     = 24 * time.Hour
+	defaultVerificationResendPeriod = time.Minute
+	defaultVerificationHourlyLimit  = 5
+)
+
+type emailVerificationClaims struct {
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"`
+	jwt.StandardClaims
+}
+
+type EmailVerificationService struct {
+	userRepo         out.UserRepository
+	verificationRepo out.EmailVerificationRepository
+	mailer           out.Mailer
+}
+
+func NewEmailVerificationService(
+	userRepo out.UserRepository,
+	verificationRepo out.EmailVerificationRepository,
+	mailer out.Mailer,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+	}
+}
+
+// SendVerificationEmail records a new single-use verification for the user's
+// current email and mails a signed link to it.
+func (s *EmailVerificationService) SendVerificationEmail(user domain.User) error {
+	if user.Email == nil {
+		return nil
+	}
+
+	verification, err := s.verificationRepo.CreateEmailVerification(domain.EmailVerification{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Email:     *user.Email,
+		ExpiresAt: time.Now().Add(s.ttl()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create email verification: %w", err)
+	}
+
+	token, err := s.signToken(verification)
+	if err != nil {
+		return fmt.Errorf("failed to sign email verification token: %w", err)
+	}
+
+	verifyUrl := fmt.Sprintf(
+		"%s/template/email/verify?token=%s",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		url.QueryEscape(token),
+	)
+
+	return s.mailer.Send(out.Mail{
+		To:       verification.Email,
+		Subject:  "Verify your email address",
+		Template: "verify_email",
+		Data: map[string]any{
+			"Name":      user.Name,
+			"VerifyUrl": verifyUrl,
+			"ExpiresIn": s.ttl().String(),
+		},
+	})
+}
+
+func (s *EmailVerificationService) VerifyEmail(token string) error {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidVerificationToken, err.Error())
+	}
+
+	verification, err := s.verificationRepo.GetEmailVerification(claims.Id)
+	if err != nil {
+		if errors.Is(err, domain.ErrEmailVerificationNotFound) {
+			return domain.ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	if verification.UsedAt != nil || time.Now().After(verification.ExpiresAt) ||
+		verification.Email != claims.Email || strconv.FormatInt(verification.UserID, 10) != claims.Subject {
+		return domain.ErrInvalidVerificationToken
+	}
+
+	if err := s.verificationRepo.MarkEmailVerificationUsed(verification.ID); err != nil {
+		return err
+	}
+
+	// The user may have changed their email since the link was sent; only the
+	// address the link was issued for, current or pending, can be verified.
+	if err := s.userRepo.MarkEmailVerified(verification.UserID, verification.Email); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResendVerificationEmail sends a new link unless the address is unknown,
+// already verified or rate limited. An address a user is changing their email
+// to is verified like a current one. The outcome is not reported so that the
+// endpoint cannot be used to discover registered emails.
+func (s *EmailVerificationService) ResendVerificationEmail(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return s.resendToPendingEmail(email)
+		}
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	limited, err := s.rateLimited(user.ID)
+	if err != nil || limited {
+		return err
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+func (s *EmailVerificationService) resendToPendingEmail(email string) error {
+	user, err := s.userRepo.GetUserByPendingEmail(email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	limited, err := s.rateLimited(user.ID)
+	if err != nil || limited {
+		return err
+	}
+
+	pending := user
+	pending.Email = user.PendingEmail
+	return s.SendVerificationEmail(pending)
+}
+
+func (s *EmailVerificationService) rateLimited(userID int64) (bool, error) {
+	latest, err := s.verificationRepo.LatestEmailVerificationAt(userID)
+	if err != nil {
+		return false, err
+	}
+	if latest != nil && time.Since(*latest) < s.resendInterval() {
+		return true, nil
+	}
+
+	count, err := s.verificationRepo.CountEmailVerificationsSince(userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return false, err
+	}
+	return count >= s.hourlyLimit(), nil
+}
+
+func (s *EmailVerificationService) signToken(verification domain.EmailVerification) (string, error) {
+	claims := emailVerificationClaims{
+		Email:   verification.Email,
+		Purpose: emailVerificationPurpose,
+		StandardClaims: jwt.StandardClaims{
+			Id:        verification.ID,
+			Subject:   strconv.FormatInt(verification.UserID, 10),
+			ExpiresAt: verification.ExpiresAt.Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JwtSecret))
+}
+
+func (s *EmailVerificationService) parseToken(token string) (emailVerificationClaims, error) {
+	var claims emailVerificationClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Method)
+		}
+		return []byte(config.AppConfig.JwtSecret), nil
+	})
+	if err != nil {
+		return emailVerificationClaims{}, err
+	}
+
+	if claims.Purpose != emailVerificationPurpose {
+		return emailVerificationClaims{}, fmt.Errorf("unexpected token purpose: %s", claims.Purpose)
+	}
+
+	return claims, nil
+}
+
+func (s *EmailVerificationService) ttl() time.Duration {
+	if config.AppConfig.EmailVerificationTTL > 0 {
+		return config.AppConfig.EmailVerificationTTL
+	}
+	return defaultEmailVerificationTTL
+}
+
+func (s *EmailVerificationService) resendInterval() time.Duration {
+	if config.AppConfig.EmailVerificationResendInterval > 0 {
+		return config.AppConfig.EmailVerificationResendInterval
+	}
+	return defaultVerificationResendPeriod
+}
+
+func (s *EmailVerificationService) hourlyLimit() int {
+	if config.AppConfig.EmailVerificationHourlyLimit > 0 {
+		return config.AppConfig.EmailVerificationHourlyLimit
+	}
+	return defaultVerificationHourlyLimit
+}
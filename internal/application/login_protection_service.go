@@ -0,0 +1,302 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLoginLockoutThreshold = 10
+	defaultLoginLockoutDuration  = 15 * time.Minute
+	defaultLoginFailureWindow    = 15 * time.Minute
+	defaultLoginIPFailureLimit   = 50
+	loginDelayAfterFailures      = 3 // Failures allowed before attempts are slowed down
+	maxLoginDelay                = time.Minute
+)
+
+// Scopes of the attempts tracked by LoginProtectionService.
+const (
+	scopeLogin          = "login"
+	scopeMFA            = "mfa"
+	scopeReauth         = "reauth"
+	scopePasswordForgot = "password_forgot"
+	scopePasswordReset  = "password_reset"
+	scopeMagicLink      = "magic_link"
+)
+
+// LoginProtectionService slows down and locks out repeated failed attempts
+// per account and per client IP.
+type LoginProtectionService struct {
+	userRepo        out.UserRepository
+	attemptRepo     out.LoginAttemptRepository
+	unlockTokenRepo out.AccountUnlockTokenRepository
+	mailer          out.Mailer
+}
+
+func NewLoginProtectionService(
+	userRepo out.UserRepository,
+	attemptRepo out.LoginAttemptRepository,
+	unlockTokenRepo out.AccountUnlockTokenRepository,
+	mailer out.Mailer,
+) *LoginProtectionService {
+	return &LoginProtectionService{
+		userRepo:        userRepo,
+		attemptRepo:     attemptRepo,
+		unlockTokenRepo: unlockTokenRepo,
+		mailer:          mailer,
+	}
+}
+
+// UnlockAccount lifts the lockouts of the user the unlock link was mailed to.
+// The token is consumed so the link cannot be replayed.
+func (s *LoginProtectionService) UnlockAccount(token string) error {
+	if token == "" {
+		return domain.ErrInvalidUnlockToken
+	}
+
+	unlockToken, err := s.unlockTokenRepo.GetAccountUnlockToken(hashToken(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrAccountUnlockTokenNotFound) {
+			return domain.ErrInvalidUnlockToken
+		}
+		return err
+	}
+
+	if unlockToken.ConsumedAt != nil || time.Now().After(unlockToken.ExpiresAt) {
+		return domain.ErrInvalidUnlockToken
+	}
+
+	if err := s.unlockTokenRepo.ConsumeAccountUnlockToken(unlockToken.ID); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUser(unlockToken.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidUnlockToken
+		}
+		return err
+	}
+
+	if user.Email != nil {
+		if err := s.attemptRepo.Unlock(accountKey(scopeLogin, emailAccount(*user.Email))); err != nil {
+			return err
+		}
+	}
+
+	if err := s.attemptRepo.Unlock(accountKey(scopeReauth, userAccount(user.ID))); err != nil {
+		return err
+	}
+
+	return s.attemptRepo.Unlock(accountKey(scopeMFA, userAccount(user.ID)))
+}
+
+// check rejects the attempt while the account is locked, while the progressive
+// delay after the last failure has not passed, or when the IP has failed too
+// often. An empty account or ip is not checked.
+func (s *LoginProtectionService) check(scope, account, ip string) error {
+	if ip != "" {
+		failures, lastFailureAt, err := s.attemptRepo.GetFailures(ipKey(scope, ip))
+		if err != nil {
+			return err
+		}
+		if failures >= s.ipFailureLimit() && lastFailureAt != nil {
+			return &domain.RetryAfterError{
+				Err:        domain.ErrTooManyAttempts,
+				RetryAfter: time.Until(lastFailureAt.Add(s.failureWindow())),
+			}
+		}
+	}
+
+	if account == "" {
+		return nil
+	}
+
+	key := accountKey(scope, account)
+
+	lockedUntil, err := s.attemptRepo.LockedUntil(key)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil && time.Now().Before(*lockedUntil) {
+		return &domain.RetryAfterError{
+			Err:        domain.ErrAccountLocked,
+			RetryAfter: time.Until(*lockedUntil),
+		}
+	}
+
+	failures, lastFailureAt, err := s.attemptRepo.GetFailures(key)
+	if err != nil {
+		return err
+	}
+	if lastFailureAt != nil {
+		if wait := time.Until(lastFailureAt.Add(loginDelay(failures))); wait > 0 {
+			return &domain.RetryAfterError{
+				Err:        domain.ErrTooManyAttempts,
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed attempt against the account and the IP. Once
+// the account reaches the lockout threshold it is locked and, when the user is
+// known, an unlock link is mailed to them.
+func (s *LoginProtectionService) recordFailure(scope, account, ip string, user *domain.User) error {
+	if ip != "" {
+		if _, err := s.attemptRepo.RecordFailure(ipKey(scope, ip), s.failureWindow()); err != nil {
+			return err
+		}
+	}
+
+	if account == "" {
+		return nil
+	}
+
+	key := accountKey(scope, account)
+
+	failures, err := s.attemptRepo.RecordFailure(key, s.failureWindow())
+	if err != nil {
+		return err
+	}
+	if failures < s.lockoutThreshold() {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(s.lockoutDuration())
+	if err := s.attemptRepo.Lock(key, lockedUntil); err != nil {
+		return err
+	}
+
+	if user == nil || user.Email == nil {
+		return nil
+	}
+
+	// The lockout is already in place, so a mail failure must not fail the
+	// attempt; the account unlocks itself once the lockout expires.
+	if err := s.sendUnlockEmail(*user, lockedUntil); err != nil {
+		log.Printf("failed to send account unlock email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// reset forgets the failures of the account after a successful attempt.
+func (s *LoginProtectionService) reset(scope, account string) error {
+	return s.attemptRepo.ResetFailures(accountKey(scope, account))
+}
+
+func (s *LoginProtectionService) sendUnlockEmail(user domain.User, lockedUntil time.Time) error {
+	token, err := s.generateUnlockToken(user.ID, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to create account unlock token: %w", err)
+	}
+
+	unlockUrl := fmt.Sprintf(
+		"%s/template/account/unlock?token=%s",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		url.QueryEscape(token),
+	)
+
+	return s.mailer.Send(out.Mail{
+		To:       *user.Email,
+		Subject:  "Your account has been locked",
+		Template: "account_locked",
+		Data: map[string]any{
+			"Name":      user.Name,
+			"UnlockUrl": unlockUrl,
+			"LockedFor": s.lockoutDuration().String(),
+		},
+	})
+}
+
+// generateUnlockToken issues an opaque, single-use token that is valid until
+// the lockout it was mailed for expires.
+func (s *LoginProtectionService) generateUnlockToken(userID int64, expiresAt time.Time) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.unlockTokenRepo.CreateAccountUnlockToken(domain.AccountUnlockToken{
+		ID:        uuid.New().String(),
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *LoginProtectionService) lockoutThreshold() int {
+	if config.AppConfig.LoginLockoutThreshold > 0 {
+		return config.AppConfig.LoginLockoutThreshold
+	}
+	return defaultLoginLockoutThreshold
+}
+
+func (s *LoginProtectionService) lockoutDuration() time.Duration {
+	if config.AppConfig.LoginLockoutDuration > 0 {
+		return config.AppConfig.LoginLockoutDuration
+	}
+	return defaultLoginLockoutDuration
+}
+
+func (s *LoginProtectionService) failureWindow() time.Duration {
+	if config.AppConfig.LoginFailureWindow > 0 {
+		return config.AppConfig.LoginFailureWindow
+	}
+	return defaultLoginFailureWindow
+}
+
+func (s *LoginProtectionService) ipFailureLimit() int {
+	if config.AppConfig.LoginIPFailureLimit > 0 {
+		return config.AppConfig.LoginIPFailureLimit
+	}
+	return defaultLoginIPFailureLimit
+}
+
+// loginDelay doubles the wait for every failure past the first few, starting
+// at one second.
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayAfterFailures {
+		return 0
+	}
+
+	delay := time.Second
+	for i := loginDelayAfterFailures; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxLoginDelay)
+}
+
+func accountKey(scope, account string) string {
+	return scope + ":account:" + account
+}
+
+func ipKey(scope, ip string) string {
+	return scope + ":ip:" + ip
+}
+
+func emailAccount(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func userAccount(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
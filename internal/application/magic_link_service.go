@@ -0,0 +1,217 @@
+package application
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const (
+	magicLinkPurpose             = "magic_link"
+	defaultMagicLinkTTL          = 15 * time.Minute
+	defaultMagicLinkResendPeriod = time.Minute
+)
+
+type magicLinkClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.StandardClaims
+}
+
+type MagicLinkService struct {
+	userRepo   out.UserRepository
+	linkRepo   out.MagicLinkRepository
+	mailer     out.Mailer
+	protection *LoginProtectionService
+}
+
+func NewMagicLinkService(
+	userRepo out.UserRepository,
+	linkRepo out.MagicLinkRepository,
+	mailer out.Mailer,
+	protection *LoginProtectionService,
+) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:   userRepo,
+		linkRepo:   linkRepo,
+		mailer:     mailer,
+		protection: protection,
+	}
+}
+
+// RequestMagicLink mails a signed, single-use login link to the user owning
+// the email. The link is bound to the device token of the requesting browser
+// and only signs in that browser. Unknown emails and rate limited requests are
+// not reported so that the endpoint cannot be used to discover registered
+// emails. Every request counts against the client IP so that one client
+// cannot flood mailboxes.
+func (s *MagicLinkService) RequestMagicLink(email, deviceToken, ip string) error {
+	if err := s.protection.check(scopeMagicLink, "", ip); err != nil {
+		return err
+	}
+	if err := s.protection.recordFailure(scopeMagicLink, "", ip, nil); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	latest, err := s.linkRepo.LatestMagicLinkAt(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(*latest) < s.resendInterval() {
+		return nil
+	}
+
+	link, err := s.linkRepo.CreateMagicLink(domain.MagicLink{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		Email:      *user.Email,
+		DeviceHash: hashToken(deviceToken),
+		ExpiresAt:  time.Now().Add(s.ttl()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create magic link: %w", err)
+	}
+
+	token, err := s.signToken(link)
+	if err != nil {
+		return fmt.Errorf("failed to sign magic link token: %w", err)
+	}
+
+	loginUrl := fmt.Sprintf(
+		"%s/template/login/magic-link?token=%s",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		url.QueryEscape(token),
+	)
+
+	// Unknown emails get no mail and no error either, so a send failure
+	// must not surface to the caller.
+	err = s.mailer.Send(out.Mail{
+		To:       link.Email,
+		Subject:  "Your sign-in link",
+		Template: "magic_link",
+		Data: map[string]any{
+			"Name":      user.Name,
+			"LoginUrl":  loginUrl,
+			"ExpiresIn": s.ttl().String(),
+		},
+	})
+	if err != nil {
+		log.Printf("failed to send magic link email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// VerifyMagicLink consumes the link and returns the user to sign in. A link
+// opened in another browser is rejected without being consumed, so the
+// requesting browser can still use it.
+func (s *MagicLinkService) VerifyMagicLink(token, deviceToken string) (domain.User, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("%w: %v", domain.ErrInvalidMagicLink, err.Error())
+	}
+
+	link, err := s.linkRepo.GetMagicLink(claims.Id)
+	if err != nil {
+		if errors.Is(err, domain.ErrMagicLinkNotFound) {
+			return domain.User{}, domain.ErrInvalidMagicLink
+		}
+		return domain.User{}, err
+	}
+
+	if link.UsedAt != nil || time.Now().After(link.ExpiresAt) || strconv.FormatInt(link.UserID, 10) != claims.Subject {
+		return domain.User{}, domain.ErrInvalidMagicLink
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(deviceToken)), []byte(link.DeviceHash)) != 1 {
+		return domain.User{}, domain.ErrMagicLinkDeviceMismatch
+	}
+
+	if err := s.linkRepo.MarkMagicLinkUsed(link.ID); err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := s.userRepo.GetUser(link.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.User{}, domain.ErrInvalidMagicLink
+		}
+		return domain.User{}, err
+	}
+
+	// The user may have changed their email since the link was sent.
+	if user.Email == nil || *user.Email != link.Email {
+		return domain.User{}, domain.ErrInvalidMagicLink
+	}
+
+	if err := checkDeletedUser(user); err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *MagicLinkService) signToken(link domain.MagicLink) (string, error) {
+	claims := magicLinkClaims{
+		Purpose: magicLinkPurpose,
+		StandardClaims: jwt.StandardClaims{
+			Id:        link.ID,
+			Subject:   strconv.FormatInt(link.UserID, 10),
+			ExpiresAt: link.ExpiresAt.Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JwtSecret))
+}
+
+func (s *MagicLinkService) parseToken(token string) (magicLinkClaims, error) {
+	var claims magicLinkClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Method)
+		}
+		return []byte(config.AppConfig.JwtSecret), nil
+	})
+	if err != nil {
+		return magicLinkClaims{}, err
+	}
+
+	if claims.Purpose != magicLinkPurpose {
+		return magicLinkClaims{}, fmt.Errorf("unexpected token purpose: %s", claims.Purpose)
+	}
+
+	return claims, nil
+}
+
+func (s *MagicLinkService) ttl() time.Duration {
+	if config.AppConfig.MagicLinkTTL > 0 {
+		return config.AppConfig.MagicLinkTTL
+	}
+	return defaultMagicLinkTTL
+}
+
+func (s *MagicLinkService) resendInterval() time.Duration {
+	if config.AppConfig.MagicLinkResendInterval > 0 {
+		return config.AppConfig.MagicLinkResendInterval
+	}
+	return defaultMagicLinkResendPeriod
+}
@@ -0,0 +1,270 @@
+package application
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	defaultTOTPIssuer  = "go-oauth2-server"
+	totpPeriod         = 30
+	totpSkew           = 1 // Accept codes from one period before and after the current one
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 5 // Random bytes per recovery code
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+type MFAService struct {
+	userRepo   out.UserRepository
+	mfaRepo    out.MFARepository
+	protection *LoginProtectionService
+}
+
+func NewMFAService(userRepo out.UserRepository, mfaRepo out.MFARepository, protection *LoginProtectionService) *MFAService {
+	return &MFAService{
+		userRepo:   userRepo,
+		mfaRepo:    mfaRepo,
+		protection: protection,
+	}
+}
+
+func (s *MFAService) GetMFAStatus(userID int64) (in.MFAStatus, error) {
+	totp, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrTOTPNotEnrolled) {
+			return in.MFAStatus{}, nil
+		}
+		return in.MFAStatus{}, err
+	}
+
+	remaining, err := s.mfaRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return in.MFAStatus{}, err
+	}
+
+	return in.MFAStatus{
+		TOTPEnabled:            totp.EnabledAt != nil,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// EnrollTOTP generates a new secret for the user. It only takes effect once
+// confirmed with a code from the authenticator app.
+func (s *MFAService) EnrollTOTP(userID int64) (in.TOTPEnrollment, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return in.TOTPEnrollment{}, err
+	}
+
+	accountName := user.Name
+	if user.Email != nil {
+		accountName = *user.Email
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer(),
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		return in.TOTPEnrollment{}, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	if err := s.mfaRepo.SaveTOTP(domain.TOTP{UserID: userID, Secret: key.Secret()}); err != nil {
+		return in.TOTPEnrollment{}, err
+	}
+
+	qrCode, err := qrCodeDataURI(key)
+	if err != nil {
+		return in.TOTPEnrollment{}, fmt.Errorf("failed to render totp qr code: %w", err)
+	}
+
+	return in.TOTPEnrollment{
+		Secret:     key.Secret(),
+		OtpauthURI: key.URL(),
+		QRCode:     qrCode,
+	}, nil
+}
+
+// ConfirmTOTP enables the enrolled secret and returns the recovery codes. The
+// codes are only stored hashed, so this is the only time they can be shown.
+func (s *MFAService) ConfirmTOTP(userID int64, code string) ([]string, error) {
+	totp, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if totp.EnabledAt != nil {
+		return nil, domain.ErrTOTPAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(totp, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.EnableTOTP(userID); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(userID)
+}
+
+// DisableTOTP turns off two-factor authentication after checking a current
+// code. Wrong codes count against the re-authentication limits.
+func (s *MFAService) DisableTOTP(userID int64, code, ip string) error {
+	if err := s.verifyMFAProtected(scopeReauth, userID, code, ip); err != nil {
+		return err
+	}
+	return s.mfaRepo.DeleteTOTP(userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a
+// current code. Wrong codes count against the re-authentication limits.
+func (s *MFAService) RegenerateRecoveryCodes(userID int64, code, ip string) ([]string, error) {
+	if err := s.verifyMFAProtected(scopeReauth, userID, code, ip); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(userID)
+}
+
+// VerifyMFA checks the second factor of a pending sign-in, tracking failures
+// per user and per client IP.
+func (s *MFAService) VerifyMFA(userID int64, code, ip string) error {
+	return s.verifyMFAProtected(scopeMFA, userID, code, ip)
+}
+
+// verifyMFAProtected runs verifyMFA behind the failure limits of the scope.
+func (s *MFAService) verifyMFAProtected(scope string, userID int64, code, ip string) error {
+	account := userAccount(userID)
+	if err := s.protection.check(scope, account, ip); err != nil {
+		return err
+	}
+
+	err := s.verifyMFA(userID, code)
+	if errors.Is(err, domain.ErrInvalidMFACode) {
+		user, userErr := s.userRepo.GetUser(userID)
+		if userErr != nil {
+			return userErr
+		}
+		if failErr := s.protection.recordFailure(scope, account, ip, &user); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.protection.reset(scope, account)
+}
+
+// verifyMFA accepts either a current authenticator code or an unused recovery
+// code, which is consumed.
+func (s *MFAService) verifyMFA(userID int64, code string) error {
+	totp, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		return err
+	}
+
+	if totp.EnabledAt == nil {
+		return domain.ErrTOTPNotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpOpts.Digits.Length() {
+		return s.verifyTOTP(totp, code)
+	}
+
+	return s.mfaRepo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+}
+
+// verifyTOTP checks the code against the time steps within the allowed skew
+// and records the matching step so the same code cannot be used twice.
+func (s *MFAService) verifyTOTP(t domain.TOTP, code string) error {
+	now := time.Now()
+	currentStep := now.Unix() / totpPeriod
+
+	for offset := -totpSkew; offset <= totpSkew; offset++ {
+		at := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+
+		expected, err := totp.GenerateCodeCustom(t.Secret, at, totpOpts)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return s.mfaRepo.UseTOTPStep(t.UserID, currentStep+int64(offset))
+		}
+	}
+
+	return domain.ErrInvalidMFACode
+}
+
+func (s *MFAService) replaceRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		token, err := randomToken(recoveryCodeLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		codes = append(codes, token[:len(token)/2]+"-"+token[len(token)/2:])
+		hashes = append(hashes, hashToken(token))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the separators and case a user may add when
+// typing a recovery code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func qrCodeDataURI(key *otp.Key) (string, error) {
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func totpIssuer() string {
+	if config.AppConfig.TOTPIssuer != "" {
+		return config.AppConfig.TOTPIssuer
+	}
+	return defaultTOTPIssuer
+}
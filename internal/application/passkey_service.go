@@ -0,0 +1,363 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	defaultWebAuthnRPDisplayName = "go-oauth2-server"
+	passkeyCeremonyTimeout       = 5 * time.Minute
+)
+
+type PasskeyService struct {
+	userRepo       out.UserRepository
+	credentialRepo out.WebAuthnCredentialRepository
+	webAuthn       *webauthn.WebAuthn
+}
+
+func NewPasskeyService(userRepo out.UserRepository, credentialRepo out.WebAuthnCredentialRepository) (*PasskeyService, error) {
+	webAuthn, err := newWebAuthn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure passkeys: %w", err)
+	}
+
+	return &PasskeyService{
+		userRepo:       userRepo,
+		credentialRepo: credentialRepo,
+		webAuthn:       webAuthn,
+	}, nil
+}
+
+func (s *PasskeyService) ListPasskeys(userID int64) ([]domain.WebAuthnCredential, error) {
+	return s.credentialRepo.ListWebAuthnCredentials(userID)
+}
+
+func (s *PasskeyService) BeginPasskeyRegistration(userID int64) (in.PasskeyCeremony, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return in.PasskeyCeremony{}, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	// Passkeys must be discoverable so they can be used without entering an
+	// email first.
+	creation, session, err := s.webAuthn.BeginRegistration(
+		user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return in.PasskeyCeremony{}, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+
+	return newPasskeyCeremony(creation, session)
+}
+
+func (s *PasskeyService) FinishPasskeyRegistration(userID int64, name string, session, response []byte) (domain.WebAuthnCredential, error) {
+	sessionData, err := decodePasskeySession(session)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return domain.WebAuthnCredential{}, fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, sessionData, parsed)
+	if err != nil {
+		return domain.WebAuthnCredential{}, fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return s.credentialRepo.CreateWebAuthnCredential(domain.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	})
+}
+
+func (s *PasskeyService) RenamePasskey(userID, passkeyID int64, name string) error {
+	return s.credentialRepo.RenameWebAuthnCredential(userID, passkeyID, name)
+}
+
+// DeletePasskey deletes one of the user's passkeys, unless it is the last way
+// left for the user to sign in. The repository checks and deletes in one
+// transaction, so concurrent removals cannot both pass the check.
+func (s *PasskeyService) DeletePasskey(userID, passkeyID int64) error {
+	return s.credentialRepo.DeleteWebAuthnCredential(userID, passkeyID)
+}
+
+// BeginPasskeyLogin starts a passwordless login. The browser lets the user
+// pick any passkey registered for this site, so no user is needed up front.
+func (s *PasskeyService) BeginPasskeyLogin() (in.PasskeyCeremony, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return in.PasskeyCeremony{}, fmt.Errorf("failed to begin passkey login: %w", err)
+	}
+
+	return newPasskeyCeremony(assertion, session)
+}
+
+// FinishPasskeyLogin signs in the owner of the passkey. A user verified passkey
+// already proves possession and a PIN or biometric, so no second factor is
+// asked for.
+func (s *PasskeyService) FinishPasskeyLogin(session, response []byte) (domain.User, error) {
+	sessionData, err := decodePasskeySession(session)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return domain.User{}, fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	var owner *webAuthnUser
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		stored, err := s.credentialRepo.GetWebAuthnCredentialByCredentialID(rawID)
+		if err != nil {
+			return nil, err
+		}
+
+		owner, err = s.loadUser(stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(owner.WebAuthnID(), userHandle) {
+			return nil, errors.New("user handle does not match the passkey owner")
+		}
+		return owner, nil
+	}, sessionData, parsed)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	if err := s.recordUsage(owner, credential); err != nil {
+		return domain.User{}, err
+	}
+
+	user := owner.user
+	if err := checkDeletedUser(user); err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+// BeginPasskeyMFA starts an assertion limited to the user's own passkeys, used
+// as the second factor after a password or social login.
+func (s *PasskeyService) BeginPasskeyMFA(userID int64) (in.PasskeyCeremony, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return in.PasskeyCeremony{}, err
+	}
+
+	if len(user.credentials) == 0 {
+		return in.PasskeyCeremony{}, domain.ErrWebAuthnCredentialNotFound
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return in.PasskeyCeremony{}, fmt.Errorf("failed to begin passkey verification: %w", err)
+	}
+
+	return newPasskeyCeremony(assertion, session)
+}
+
+func (s *PasskeyService) FinishPasskeyMFA(userID int64, session, response []byte) error {
+	sessionData, err := decodePasskeySession(session)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	credential, err := s.webAuthn.ValidateLogin(user, sessionData, parsed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrWebAuthnFailed, err.Error())
+	}
+
+	return s.recordUsage(user, credential)
+}
+
+// recordUsage stores the new signature counter. A counter that did not
+// increase means the authenticator may have been cloned, so the assertion is
+// rejected.
+func (s *PasskeyService) recordUsage(user *webAuthnUser, credential *webauthn.Credential) error {
+	if credential.Authenticator.CloneWarning {
+		return fmt.Errorf("%w: signature counter did not increase", domain.ErrWebAuthnFailed)
+	}
+
+	for _, stored := range user.credentials {
+		if bytes.Equal(stored.CredentialID, credential.ID) {
+			return s.credentialRepo.UpdateWebAuthnCredentialUsage(stored.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+		}
+	}
+
+	return domain.ErrWebAuthnCredentialNotFound
+}
+
+func (s *PasskeyService) loadUser(userID int64) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepo.ListWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func newPasskeyCeremony(options any, session *webauthn.SessionData) (in.PasskeyCeremony, error) {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return in.PasskeyCeremony{}, fmt.Errorf("failed to encode passkey session: %w", err)
+	}
+
+	return in.PasskeyCeremony{Options: options, Session: encoded}, nil
+}
+
+func decodePasskeySession(session []byte) (webauthn.SessionData, error) {
+	var sessionData webauthn.SessionData
+	if len(session) == 0 || json.Unmarshal(session, &sessionData) != nil {
+		return webauthn.SessionData{}, fmt.Errorf("%w: no passkey ceremony in progress", domain.ErrWebAuthnFailed)
+	}
+	return sessionData, nil
+}
+
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	baseUrl, err := url.Parse(strings.TrimRight(config.AppConfig.AppBaseUrl, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	rpID := config.AppConfig.WebAuthnRPID
+	if rpID == "" {
+		rpID = baseUrl.Hostname()
+	}
+
+	displayName := config.AppConfig.WebAuthnRPDisplayName
+	if displayName == "" {
+		displayName = defaultWebAuthnRPDisplayName
+	}
+
+	origins := config.AppConfig.WebAuthnRPOrigins
+	if len(origins) == 0 {
+		origins = []string{baseUrl.Scheme + "://" + baseUrl.Host}
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTimeout, TimeoutUVD: passkeyCeremonyTimeout}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+}
+
+// webAuthnUser adapts a user and their stored passkeys to the webauthn
+// library. The user handle is the user ID, which carries no personal data.
+type webAuthnUser struct {
+	user        domain.User
+	credentials []domain.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatInt(u.user.ID, 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	if u.user.Email != nil {
+		return *u.user.Email
+	}
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+
+	for _, stored := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(stored.Transports))
+		for _, transport := range stored.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
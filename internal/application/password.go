@@ -0,0 +1,15 @@
+package application
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+// verifyPassword checks the password against the stored hash. Users without a
+// password, such as social-only users, never match.
+func verifyPassword(hasher out.PasswordHasher, user domain.User, password string) (bool, error) {
+	if user.Password == "" {
+		return false, nil
+	}
+	return hasher.Verify(password, user.Password)
+}
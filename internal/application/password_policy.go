@@ -0,0 +1,191 @@
+package application
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+const (
+	defaultPasswordMinLength           = 8
+	defaultPasswordMaxLength           = 72 // Bytes; bcrypt rejects longer passwords
+	defaultPasswordMinCharacterClasses = 2
+	minSimilarityTermLength            = 4 // Shorter parts of the email or name are not checked
+	breachedPasswordPrefixLength       = 5
+)
+
+// commonPasswords are rejected regardless of the configured banned list.
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd",
+	"12345678", "123456789", "1234567890", "87654321", "11111111",
+	"qwertyui", "qwerty123", "1q2w3e4r", "1qaz2wsx", "qazwsxedc",
+	"abcd1234", "abc12345", "iloveyou", "sunshine", "princess",
+	"football", "baseball", "welcome1", "letmein1", "trustno1",
+	"superman", "starwars", "whatever", "dragon12", "monkey12",
+	"changeme", "admin123", "zaq12wsx", "asdfghjkl", "00000000",
+}
+
+// PasswordPolicy checks new passwords against the configured rules and the
+// breached password corpus.
+type PasswordPolicy struct {
+	breachedRepo out.BreachedPasswordRepository
+}
+
+func NewPasswordPolicy(breachedRepo out.BreachedPasswordRepository) *PasswordPolicy {
+	return &PasswordPolicy{
+		breachedRepo: breachedRepo,
+	}
+}
+
+// Check reports every rule the password breaks as a field error on
+// "password". The email and name of the user must not be part of the
+// password.
+func (p *PasswordPolicy) Check(password string, user domain.User) error {
+	var fields []domain.FieldError
+	reject := func(code, message string) {
+		fields = append(fields, domain.FieldError{Field: "password", Code: code, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.minLength() {
+		reject("PASSWORD_TOO_SHORT", fmt.Sprintf("The password must be at least %d characters long.", p.minLength()))
+	}
+
+	if len(password) > p.maxLength() {
+		reject("PASSWORD_TOO_LONG", fmt.Sprintf("The password must be at most %d bytes long.", p.maxLength()))
+	}
+
+	if characterClasses(password) < p.minCharacterClasses() {
+		reject("PASSWORD_TOO_SIMPLE", fmt.Sprintf(
+			"The password must contain at least %d of lowercase letters, uppercase letters, digits and symbols.",
+			p.minCharacterClasses(),
+		))
+	}
+
+	if p.isBanned(password) {
+		reject("PASSWORD_BANNED", "The password is too common.")
+	}
+
+	if isSimilarToAccount(password, user) {
+		reject("PASSWORD_SIMILAR_TO_ACCOUNT", "The password must not contain your email or name.")
+	}
+
+	breached, err := p.isBreached(password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		reject("PASSWORD_BREACHED", "The password has appeared in a data breach. Choose a different one.")
+	}
+
+	if len(fields) > 0 {
+		return &domain.ValidationError{
+			Err:    domain.ErrPasswordPolicy,
+			Fields: fields,
+		}
+	}
+
+	return nil
+}
+
+func (p *PasswordPolicy) isBanned(password string) bool {
+	password = strings.ToLower(password)
+
+	for _, banned := range commonPasswords {
+		if password == banned {
+			return true
+		}
+	}
+
+	for _, banned := range config.AppConfig.PasswordBannedList {
+		if password == strings.ToLower(strings.TrimSpace(banned)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBreached looks the password up by the prefix of its SHA-1 hash and
+// matches the suffix locally.
+func (p *PasswordPolicy) isBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := p.breachedRepo.GetRange(hash[:breachedPasswordPrefixLength])
+	if err != nil {
+		return false, fmt.Errorf("failed to check breached passwords: %w", err)
+	}
+
+	return suffixes[hash[breachedPasswordPrefixLength:]] > 0, nil
+}
+
+func (p *PasswordPolicy) minLength() int {
+	if config.AppConfig.PasswordMinLength > 0 {
+		return config.AppConfig.PasswordMinLength
+	}
+	return defaultPasswordMinLength
+}
+
+func (p *PasswordPolicy) maxLength() int {
+	if config.AppConfig.PasswordMaxLength > 0 {
+		return config.AppConfig.PasswordMaxLength
+	}
+	return defaultPasswordMaxLength
+}
+
+func (p *PasswordPolicy) minCharacterClasses() int {
+	if config.AppConfig.PasswordMinCharacterClasses > 0 {
+		return config.AppConfig.PasswordMinCharacterClasses
+	}
+	return defaultPasswordMinCharacterClasses
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// isSimilarToAccount reports whether the password contains the local part of
+// the email, the name, or any word of them long enough to matter.
+func isSimilarToAccount(password string, user domain.User) bool {
+	password = strings.ToLower(password)
+
+	var localPart string
+	if user.Email != nil {
+		localPart, _, _ = strings.Cut(strings.ToLower(*user.Email), "@")
+	}
+	name := strings.ToLower(user.Name)
+
+	terms := []string{localPart, strings.Join(strings.Fields(name), "")}
+	terms = append(terms, strings.FieldsFunc(localPart, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+	terms = append(terms, strings.Fields(name)...)
+
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minSimilarityTermLength && strings.Contains(password, term) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,193 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+const (
+	defaultPasswordResetTTL          = time.Hour
+	defaultPasswordResetResendPeriod = time.Minute
+)
+
+type PasswordResetService struct {
+	userRepo       out.UserRepository
+	resetRepo      out.PasswordResetRepository
+	mailer         out.Mailer
+	protection     *LoginProtectionService
+	passwordPolicy *PasswordPolicy
+	hasher         out.PasswordHasher
+}
+
+func NewPasswordResetService(
+	userRepo out.UserRepository,
+	resetRepo out.PasswordResetRepository,
+	mailer out.Mailer,
+	protection *LoginProtectionService,
+	passwordPolicy *PasswordPolicy,
+	hasher out.PasswordHasher,
+) *PasswordResetService {
+	return &PasswordResetService{
+		userRepo:       userRepo,
+		resetRepo:      resetRepo,
+		mailer:         mailer,
+		protection:     protection,
+		passwordPolicy: passwordPolicy,
+		hasher:         hasher,
+	}
+}
+
+// RequestPasswordReset mails a single-use reset link to the user owning the
+// email. Only a hash of the token is stored. Unknown emails and rate limited
+// requests are not reported so that the endpoint cannot be used to discover
+// registered emails. Every request counts against the client IP so that one
+// client cannot flood mailboxes.
+func (s *PasswordResetService) RequestPasswordReset(email, ip string) error {
+	if err := s.protection.check(scopePasswordForgot, "", ip); err != nil {
+		return err
+	}
+	if err := s.protection.recordFailure(scopePasswordForgot, "", ip, nil); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	latest, err := s.resetRepo.LatestPasswordResetAt(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(*latest) < s.resendInterval() {
+		return nil
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+
+	_, err = s.resetRepo.CreatePasswordReset(domain.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.ttl()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	resetUrl := fmt.Sprintf(
+		"%s/template/password/reset?token=%s",
+		strings.TrimRight(config.AppConfig.AppBaseUrl, "/"),
+		url.QueryEscape(token),
+	)
+
+	// A failed send is logged rather than returned, so that the response
+	// does not tell registered emails apart from unknown ones.
+	err = s.mailer.Send(out.Mail{
+		To:       *user.Email,
+		Subject:  "Reset your password",
+		Template: "password_reset",
+		Data: map[string]any{
+			"Name":      user.Name,
+			"ResetUrl":  resetUrl,
+			"ExpiresIn": s.ttl().String(),
+		},
+	})
+	if err != nil {
+		log.Printf("failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes the token, sets the new password and signs the user
+// out of every existing session. Invalid tokens count against the client IP
+// so that tokens cannot be guessed.
+func (s *PasswordResetService) ResetPassword(token, password, ip string) error {
+	if err := s.protection.check(scopePasswordReset, "", ip); err != nil {
+		return err
+	}
+
+	reset, err := s.resetRepo.GetPasswordResetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, domain.ErrPasswordResetNotFound) {
+			return s.rejectToken(ip)
+		}
+		return err
+	}
+
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return s.rejectToken(ip)
+	}
+
+	user, err := s.userRepo.GetUser(reset.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+
+	// Checked before the token is consumed so the user can pick another
+	// password with the same link.
+	if err := s.passwordPolicy.Check(password, user); err != nil {
+		return err
+	}
+
+	if err := s.resetRepo.MarkPasswordResetUsed(reset.ID); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateUserPassword(reset.UserID, hashedPassword); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+
+	if err := s.userRepo.RevokeUserSessions(reset.UserID, time.Now()); err != nil {
+		return err
+	}
+
+	// Links requested before this reset must not be usable to reset again.
+	return s.resetRepo.InvalidatePasswordResets(reset.UserID)
+}
+
+func (s *PasswordResetService) rejectToken(ip string) error {
+	if err := s.protection.recordFailure(scopePasswordReset, "", ip, nil); err != nil {
+		return err
+	}
+	return domain.ErrInvalidPasswordResetToken
+}
+
+func (s *PasswordResetService) ttl() time.Duration {
+	if config.AppConfig.PasswordResetTTL > 0 {
+		return config.AppConfig.PasswordResetTTL
+	}
+	return defaultPasswordResetTTL
+}
+
+func (s *PasswordResetService) resendInterval() time.Duration {
+	if config.AppConfig.PasswordResetResendInterval > 0 {
+		return config.AppConfig.PasswordResetResendInterval
+	}
+	return defaultPasswordResetResendPeriod
+}
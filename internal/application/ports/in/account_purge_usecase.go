@@ -0,0 +1,5 @@
+package in
+
+type AccountPurgeUsecase interface {
+	PurgeDeletedUsers() (int, error)
+}
@@ -0,0 +1,5 @@
+package in
+
+type AccountUnlockUsecase interface {
+	UnlockAccount(token string) error
+}
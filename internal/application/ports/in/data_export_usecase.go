@@ -0,0 +1,18 @@
+package in
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type DataExportResult struct {
+	Status      domain.DataExportStatus
+	DownloadUrl string     // Signed link to the archive, set once it is ready
+	ExpiresAt   *time.Time // When the download link stops working
+}
+
+type DataExportUsecase interface {
+	RequestDataExport(userID int64) (DataExportResult, error)
+	OpenDataExport(token string) (string, error)
+}
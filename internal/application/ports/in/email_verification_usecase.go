@@ -0,0 +1,11 @@
+package in
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type EmailVerificationUsecase interface {
+	SendVerificationEmail(user domain.User) error
+	VerifyEmail(token string) error
+	ResendVerificationEmail(email string) error
+}
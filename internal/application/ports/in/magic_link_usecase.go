@@ -0,0 +1,10 @@
+package in
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type MagicLinkUsecase interface {
+	RequestMagicLink(email, deviceToken, ip string) error
+	VerifyMagicLink(token, deviceToken string) (domain.User, error)
+}
@@ -0,0 +1,21 @@
+package in
+
+type TOTPEnrollment struct {
+	Secret     string
+	OtpauthURI string
+	QRCode     string // PNG data URI of the otpauth URI
+}
+
+type MFAStatus struct {
+	TOTPEnabled            bool
+	RecoveryCodesRemaining int
+}
+
+type MFAUsecase interface {
+	GetMFAStatus(userID int64) (MFAStatus, error)
+	EnrollTOTP(userID int64) (TOTPEnrollment, error)
+	ConfirmTOTP(userID int64, code string) ([]string, error)
+	DisableTOTP(userID int64, code, ip string) error
+	RegenerateRecoveryCodes(userID int64, code, ip string) ([]string, error)
+	VerifyMFA(userID int64, code, ip string) error
+}
@@ -0,0 +1,23 @@
+package in
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+// PasskeyCeremony is the first half of a WebAuthn registration or login.
+type PasskeyCeremony struct {
+	Options any    // Public key options to hand to navigator.credentials
+	Session []byte // Challenge state the caller keeps until the ceremony is finished
+}
+
+type PasskeyUsecase interface {
+	ListPasskeys(userID int64) ([]domain.WebAuthnCredential, error)
+	BeginPasskeyRegistration(userID int64) (PasskeyCeremony, error)
+	FinishPasskeyRegistration(userID int64, name string, session, response []byte) (domain.WebAuthnCredential, error)
+	RenamePasskey(userID, passkeyID int64, name string) error
+	DeletePasskey(userID, passkeyID int64) error
+	BeginPasskeyLogin() (PasskeyCeremony, error)
+	FinishPasskeyLogin(session, response []byte) (domain.User, error)
+	BeginPasskeyMFA(userID int64) (PasskeyCeremony, error)
+	FinishPasskeyMFA(userID int64, session, response []byte) error
+}
@@ -0,0 +1,6 @@
+package in
+
+type PasswordResetUsecase interface {
+	RequestPasswordReset(email, ip string) error
+	ResetPassword(token, password, ip string) error
+}
@@ -0,0 +1,18 @@
+package in
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type SessionUsecase interface {
+	StartSession(userID int64, ipAddress, userAgent string) (domain.UserSession, error)
+	TouchSession(userID int64, sessionID, ipAddress string) error
+	RememberSession(userID int64, sessionID string) (string, time.Time, error)
+	ResumeSession(token, ipAddress string) (domain.UserSession, string, error)
+	EndSession(userID int64, sessionID string) error
+	ListSessions(userID int64) ([]domain.UserSession, error)
+	RevokeSession(userID int64, sessionID string) error
+	RevokeOtherSessions(userID int64, currentSessionID string) error
+}
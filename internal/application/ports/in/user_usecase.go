@@ -1,9 +1,10 @@
 package in
 
 import (
+	"time"
+
 	"github.com/Joe5451/go-oauth2-server/internal/domain"
 	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
-	"github.com/golang-jwt/jwt"
 )
 
 type RegisterUserRequest struct {
@@ -12,18 +13,24 @@ type RegisterUserRequest struct {
 	Name     string
 }
 
+type UpdateUserRequest struct {
+	Name      *string
+	Email     *string
+	UpdatedAt time.Time // The version of the user the changes are based on
+	AuthTime  time.Time // When the session authenticated; changing the email requires a recent one
+}
+
 type AuthSocialUserStatus string
 
 const (
 	AuthSuccess      AuthSocialUserStatus = "success"       // Successful authentication
 	AuthLinkRequired AuthSocialUserStatus = "link_required" // Link required for the account
+	AuthMFARequired  AuthSocialUserStatus = "mfa_required"  // Second factor required to finish signing in
 )
 
-type LinkTokenClaims struct {
-	UserID               int64                  `json:"user_id"`
-	SocialAccountID      int64                  `json:"social_account_id"`
-	LinkedSocialAccounts []domain.SocialAccount `json:"linked_social_accounts"`
-	jwt.StandardClaims
+// PendingLink describes the social account link a link token is waiting for.
+type PendingLink struct {
+	LinkedSocialAccounts []domain.SocialAccount // Accounts the user can confirm the link with
 }
 
 type AuthSocialUserResult struct {
@@ -34,14 +41,21 @@ type AuthSocialUserResult struct {
 
 type UserUsecase interface {
 	Register(req RegisterUserRequest) error
-	AuthenticateUser(email, password string) (domain.User, error)
-	SocialAuthUrl(provider socialproviders.SocialProvider, state, redirectUri string) (string, error)
-	AuthenticateSocialUser(provider socialproviders.SocialProvider, authorizationCode, redirectUri string) (AuthSocialUserResult, error)
-	LinkUserWithSocialAccount(provider socialproviders.SocialProvider, authCode string, linkToken string, redirectUri string) (domain.User, error)
-	ValidateLinkToken(linkToken string) (LinkTokenClaims, error)
+	AuthenticateUser(email, password, ip string) (domain.User, error)
+	SocialAuthUrl(provider socialproviders.SocialProvider, redirectUri string, params socialproviders.AuthParams) (string, error)
+	AuthenticateSocialUser(provider socialproviders.SocialProvider, authorizationCode, redirectUri string, params socialproviders.AuthParams) (AuthSocialUserResult, error)
+	LinkUserWithSocialAccount(provider socialproviders.SocialProvider, authCode string, linkToken string, redirectUri string, params socialproviders.AuthParams) (domain.User, error)
+	ValidateLinkToken(linkToken string) (PendingLink, error)
 	GetUser(userID int64) (domain.User, error)
-	UpdateUser(userID int64, user domain.User) error
+	GetLoginMethods(userID int64) (domain.LoginMethods, error)
+	IsSessionRevoked(userID int64, authTime time.Time) (bool, error)
+	CheckRecentAuth(authTime time.Time) error
+	Reauthenticate(userID int64, password, ip string) error
+	ReauthenticateSocialUser(userID int64, provider socialproviders.SocialProvider, authorizationCode, redirectUri string, params socialproviders.AuthParams) error
+	UpdateUser(userID int64, req UpdateUserRequest) (domain.User, error)
 	UpdateUserAvatar(userID int64, avatarUrl string) error
-	LinkSocialAccount(userID int64, provider socialproviders.SocialProvider, authCode, redirectUri string) error
+	ChangePassword(userID int64, currentPassword, newPassword string, authTime time.Time, ip string) error
+	DeleteUser(userID int64, password string, authTime time.Time, ip string) (time.Time, error)
+	LinkSocialAccount(userID int64, provider socialproviders.SocialProvider, authCode, redirectUri string, params socialproviders.AuthParams) error
 	UnlinkSocialAccount(userID int64, provider socialproviders.SocialProvider) error
 }
@@ -0,0 +1,12 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type AccountUnlockTokenRepository interface {
+	CreateAccountUnlockToken(token domain.AccountUnlockToken) (domain.AccountUnlockToken, error)
+	GetAccountUnlockToken(tokenHash string) (domain.AccountUnlockToken, error)
+	ConsumeAccountUnlockToken(tokenID string) error
+	ListAccountUnlockTokens(userID int64) ([]domain.AccountUnlockToken, error)
+}
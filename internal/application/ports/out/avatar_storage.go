@@ -0,0 +1,5 @@
+package out
+
+type AvatarStorage interface {
+	DeleteAvatar(avatarUrl string) error
+}
@@ -0,0 +1,10 @@
+package out
+
+// BreachedPasswordRepository looks up breached passwords by the first five
+// hex characters of their SHA-1 hash, so the full hash never leaves the
+// caller.
+type BreachedPasswordRepository interface {
+	// GetRange returns the breach count of every hash suffix sharing the
+	// prefix.
+	GetRange(prefix string) (map[string]int, error)
+}
@@ -0,0 +1,12 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type DataExportRepository interface {
+	CreateDataExport(export domain.DataExport) (domain.DataExport, error)
+	GetDataExport(exportID string) (domain.DataExport, error)
+	GetLatestDataExport(userID int64) (domain.DataExport, error)
+	UpdateDataExport(export domain.DataExport) error
+}
@@ -0,0 +1,16 @@
+package out
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type EmailVerificationRepository interface {
+	CreateEmailVerification(verification domain.EmailVerification) (domain.EmailVerification, error)
+	GetEmailVerification(verificationID string) (domain.EmailVerification, error)
+	MarkEmailVerificationUsed(verificationID string) error
+	CountEmailVerificationsSince(userID int64, since time.Time) (int, error)
+	LatestEmailVerificationAt(userID int64) (*time.Time, error)
+	ListEmailVerifications(userID int64) ([]domain.EmailVerification, error)
+}
@@ -0,0 +1,11 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type ExportStorage interface {
+	// SaveExportArchive stores the files as one archive and returns its path.
+	SaveExportArchive(export domain.DataExport, files map[string][]byte) (string, error)
+	DeleteExportArchives(userID int64) error
+}
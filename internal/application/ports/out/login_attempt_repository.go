@@ -0,0 +1,16 @@
+package out
+
+import (
+	"time"
+)
+
+// LoginAttemptRepository tracks failed attempts and lockouts by key, e.g. an
+// account or a client IP.
+type LoginAttemptRepository interface {
+	RecordFailure(key string, window time.Duration) (int, error)
+	GetFailures(key string) (int, *time.Time, error)
+	ResetFailures(key string) error
+	Lock(key string, until time.Time) error
+	LockedUntil(key string) (*time.Time, error)
+	Unlock(key string) error
+}
@@ -0,0 +1,15 @@
+package out
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type MagicLinkRepository interface {
+	CreateMagicLink(link domain.MagicLink) (domain.MagicLink, error)
+	GetMagicLink(linkID string) (domain.MagicLink, error)
+	MarkMagicLinkUsed(linkID string) error
+	LatestMagicLinkAt(userID int64) (*time.Time, error)
+	ListMagicLinks(userID int64) ([]domain.MagicLink, error)
+}
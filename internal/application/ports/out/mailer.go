@@ -0,0 +1,12 @@
+package out
+
+type Mail struct {
+	To       string
+	Subject  string
+	Template string         // Template pair name, rendered as <name>.html.tmpl and <name>.txt.tmpl
+	Data     map[string]any // Values available to the templates
+}
+
+type Mailer interface {
+	Send(mail Mail) error
+}
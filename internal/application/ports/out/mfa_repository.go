@@ -0,0 +1,17 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type MFARepository interface {
+	GetTOTP(userID int64) (domain.TOTP, error)
+	SaveTOTP(totp domain.TOTP) error
+	EnableTOTP(userID int64) error
+	UseTOTPStep(userID int64, step int64) error
+	DeleteTOTP(userID int64) error
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	UseRecoveryCode(userID int64, codeHash string) error
+	CountRecoveryCodes(userID int64) (int, error)
+	ListRecoveryCodes(userID int64) ([]domain.RecoveryCode, error)
+}
@@ -0,0 +1,11 @@
+package out
+
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password, including the algorithm
+	// and parameters it was made with.
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether the hash was made with another algorithm or
+	// other parameters than the current ones.
+	NeedsRehash(encoded string) bool
+}
@@ -0,0 +1,16 @@
+package out
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type PasswordResetRepository interface {
+	CreatePasswordReset(reset domain.PasswordReset) (domain.PasswordReset, error)
+	GetPasswordResetByTokenHash(tokenHash string) (domain.PasswordReset, error)
+	MarkPasswordResetUsed(resetID int64) error
+	InvalidatePasswordResets(userID int64) error
+	LatestPasswordResetAt(userID int64) (*time.Time, error)
+	ListPasswordResets(userID int64) ([]domain.PasswordReset, error)
+}
@@ -0,0 +1,11 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type SocialLinkTokenRepository interface {
+	CreateSocialLinkToken(token domain.SocialLinkToken) (domain.SocialLinkToken, error)
+	GetSocialLinkToken(tokenHash string) (domain.SocialLinkToken, error)
+	ConsumeSocialLinkToken(tokenID string) error
+}
@@ -1,6 +1,8 @@
 package out
 
 import (
+	"time"
+
 	"github.com/Joe5451/go-oauth2-server/internal/domain"
 )
 
@@ -8,10 +10,22 @@ type UserRepository interface {
 	CreateUser(user domain.User) (domain.User, error)
 	GetUser(userID int64) (domain.User, error)
 	GetUserByEmail(email string) (domain.User, error)
+	GetUserByPendingEmail(email string) (domain.User, error)
 	UpdateOrCreateSocialAccount(socialAccount domain.SocialAccount) (domain.SocialAccount, error)
-	GetSocialAccountByProviderUserID(providerUserID string) (domain.SocialAccount, error)
+	ListSocialAccounts(userID int64) ([]domain.SocialAccount, error)
+	GetLoginMethods(userID int64) (domain.LoginMethods, error)
+	GetSocialAccountByProviderUserID(provider, providerUserID string) (domain.SocialAccount, error)
 	UpdateSocialAccountUserID(socialAccountID, userID int64) error
-	UpdateUser(usreID int64, user domain.User) error
+	UpdateUser(user domain.User) (domain.User, error)
 	UpdateUserAvatar(userID int64, avatarUrl string) error
 	UnlinkSocialAccount(userID int64, provider string) error
+	MarkEmailVerified(userID int64, email string) error
+	UpdateUserPassword(userID int64, password string) error
+	RehashUserPassword(userID int64, currentHash, newHash string) error
+	RevokeUserSessions(userID int64, revokedAt time.Time) error
+	GetSessionRevocation(userID int64) (domain.SessionRevocation, error)
+	SoftDeleteUser(userID int64, deletedAt time.Time) error
+	RestoreUser(userID int64) error
+	GetUsersDeletedBefore(before time.Time) ([]domain.User, error)
+	PurgeUser(userID int64, deletedBefore time.Time) error
 }
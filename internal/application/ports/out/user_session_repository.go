@@ -0,0 +1,20 @@
+package out
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type UserSessionRepository interface {
+	CreateUserSession(session domain.UserSession) (domain.UserSession, error)
+	GetUserSession(userID int64, sessionID string) (domain.UserSession, error)
+	ListUserSessions(userID int64) ([]domain.UserSession, error)
+	GetRememberedUserSession(tokenHash string) (domain.UserSession, error)
+	GetUserSessionByRetiredRememberToken(tokenHash string) (domain.UserSession, time.Time, error)
+	TouchUserSession(sessionID, ipAddress string, lastSeenAt time.Time) error
+	RememberUserSession(sessionID, tokenHash string, rememberedUntil time.Time) error
+	RotateRememberToken(sessionID, oldTokenHash, newTokenHash string) error
+	DeleteUserSession(userID int64, sessionID string) error
+	DeleteOtherUserSessions(userID int64, keepSessionID string) error
+}
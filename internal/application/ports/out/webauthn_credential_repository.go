@@ -0,0 +1,14 @@
+package out
+
+import (
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+)
+
+type WebAuthnCredentialRepository interface {
+	CreateWebAuthnCredential(credential domain.WebAuthnCredential) (domain.WebAuthnCredential, error)
+	GetWebAuthnCredentialByCredentialID(credentialID []byte) (domain.WebAuthnCredential, error)
+	ListWebAuthnCredentials(userID int64) ([]domain.WebAuthnCredential, error)
+	UpdateWebAuthnCredentialUsage(id int64, signCount uint32, backupState bool) error
+	RenameWebAuthnCredential(userID, id int64, name string) error
+	DeleteWebAuthnCredential(userID, id int64) error
+}
@@ -0,0 +1,260 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
+	"github.com/google/uuid"
+)
+
+const (
+	// sessionTouchInterval limits how often the last-seen time of a session is
+	// written, so that not every request updates the index.
+	sessionTouchInterval = time.Minute
+
+	// rememberTokenReuseGrace is how long a rotated remember-me token is still
+	// accepted, for requests the browser sent in parallel with the cookie
+	// before the rotated one reached it.
+	rememberTokenReuseGrace = 30 * time.Second
+
+	defaultSessionRememberDuration = 30 * 24 * time.Hour
+)
+
+// SessionService keeps the per-user index of signed-in sessions. A session
+// missing from the index is signed out on its next request.
+type SessionService struct {
+	sessionRepo out.UserSessionRepository
+	userRepo    out.UserRepository
+}
+
+func NewSessionService(sessionRepo out.UserSessionRepository, userRepo out.UserRepository) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// StartSession adds a completed sign-in to the user's session index. A user
+// signing in during the grace period of their account deletion gets their
+// account back here, after every factor has been checked, and not when the
+// first factor passes.
+func (s *SessionService) StartSession(userID int64, ipAddress, userAgent string) (domain.UserSession, error) {
+	user, err := s.userRepo.GetUser(userID)
+	if err != nil {
+		return domain.UserSession{}, err
+	}
+
+	if err := restoreDeletedUser(s.userRepo, &user); err != nil {
+		return domain.UserSession{}, err
+	}
+
+	return s.sessionRepo.CreateUserSession(domain.UserSession{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Device:    describeDevice(userAgent),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+// TouchSession records that the session is still in use, returning
+// ErrSessionNotFound once it has been revoked.
+func (s *SessionService) TouchSession(userID int64, sessionID, ipAddress string) error {
+	session, err := s.getSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(session.LastSeenAt) < sessionTouchInterval && session.IPAddress == ipAddress {
+		return nil
+	}
+
+	return s.sessionRepo.TouchUserSession(session.ID, ipAddress, time.Now())
+}
+
+// RememberSession issues a remember-me token that can resume the session
+// after it expires, until the returned time.
+func (s *SessionService) RememberSession(userID int64, sessionID string) (string, time.Time, error) {
+	session, err := s.getSession(userID, sessionID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate remember-me token: %w", err)
+	}
+
+	rememberedUntil := time.Now().Add(rememberDuration())
+	if err := s.sessionRepo.RememberUserSession(session.ID, hashToken(token), rememberedUntil); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, rememberedUntil, nil
+}
+
+// ResumeSession returns the session a remember-me token was issued for and
+// the token that replaces it. It fails with ErrSessionNotFound once the token
+// has expired or the session has been revoked.
+//
+// Every token is used once. A rotated token that comes back has been copied,
+// so the session is revoked, signing out both the thief and the device. Only
+// within rememberTokenReuseGrace of the rotation is it still accepted, without
+// a replacement, as the device then holds the new token already.
+func (s *SessionService) ResumeSession(token, ipAddress string) (domain.UserSession, string, error) {
+	if token == "" {
+		return domain.UserSession{}, "", domain.ErrSessionNotFound
+	}
+
+	tokenHash := hashToken(token)
+
+	session, err := s.sessionRepo.GetRememberedUserSession(tokenHash)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		session, err := s.resumeWithRetiredToken(tokenHash, ipAddress)
+		return session, "", err
+	}
+	if err != nil {
+		return domain.UserSession{}, "", err
+	}
+
+	newToken, err := randomToken(32)
+	if err != nil {
+		return domain.UserSession{}, "", fmt.Errorf("failed to generate remember-me token: %w", err)
+	}
+
+	err = s.sessionRepo.RotateRememberToken(session.ID, tokenHash, hashToken(newToken))
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		// A parallel request rotated the token since it was read.
+		session, err := s.resumeWithRetiredToken(tokenHash, ipAddress)
+		return session, "", err
+	}
+	if err != nil {
+		return domain.UserSession{}, "", err
+	}
+
+	if err := s.sessionRepo.TouchUserSession(session.ID, ipAddress, time.Now()); err != nil {
+		return domain.UserSession{}, "", err
+	}
+
+	return session, newToken, nil
+}
+
+// resumeWithRetiredToken resumes the session of a remember-me token rotated
+// within the grace period. Past it the reuse is taken for theft, and the
+// session is revoked with ErrSessionNotFound returned.
+func (s *SessionService) resumeWithRetiredToken(tokenHash, ipAddress string) (domain.UserSession, error) {
+	session, retiredAt, err := s.sessionRepo.GetUserSessionByRetiredRememberToken(tokenHash)
+	if err != nil {
+		return domain.UserSession{}, err
+	}
+
+	if time.Since(retiredAt) < rememberTokenReuseGrace {
+		if session.RememberedUntil == nil || !time.Now().Before(*session.RememberedUntil) {
+			return domain.UserSession{}, domain.ErrSessionNotFound
+		}
+		if err := s.sessionRepo.TouchUserSession(session.ID, ipAddress, time.Now()); err != nil {
+			return domain.UserSession{}, err
+		}
+		return session, nil
+	}
+
+	log.Printf("revoking session %s of user %d after a rotated remember-me token was reused", session.ID, session.UserID)
+
+	if err := s.sessionRepo.DeleteUserSession(session.UserID, session.ID); err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+		return domain.UserSession{}, err
+	}
+
+	return domain.UserSession{}, domain.ErrSessionNotFound
+}
+
+// EndSession removes a session that signed out from the index.
+func (s *SessionService) EndSession(userID int64, sessionID string) error {
+	err := s.RevokeSession(userID, sessionID)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *SessionService) ListSessions(userID int64) ([]domain.UserSession, error) {
+	return s.sessionRepo.ListUserSessions(userID)
+}
+
+func (s *SessionService) RevokeSession(userID int64, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return domain.ErrSessionNotFound
+	}
+	return s.sessionRepo.DeleteUserSession(userID, sessionID)
+}
+
+func (s *SessionService) RevokeOtherSessions(userID int64, currentSessionID string) error {
+	if _, err := uuid.Parse(currentSessionID); err != nil {
+		return domain.ErrSessionNotFound
+	}
+	return s.sessionRepo.DeleteOtherUserSessions(userID, currentSessionID)
+}
+
+func (s *SessionService) getSession(userID int64, sessionID string) (domain.UserSession, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return domain.UserSession{}, domain.ErrSessionNotFound
+	}
+	return s.sessionRepo.GetUserSession(userID, sessionID)
+}
+
+func rememberDuration() time.Duration {
+	if config.AppConfig.SessionRememberDuration > 0 {
+		return config.AppConfig.SessionRememberDuration
+	}
+	return defaultSessionRememberDuration
+}
+
+// describeDevice names the browser and operating system of a user agent, e.g.
+// "Chrome on Windows". Only the common ones are recognized.
+func describeDevice(userAgent string) string {
+	browser := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	})
+
+	os := firstMatch(userAgent, [][2]string{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+func firstMatch(userAgent string, patterns [][2]string) string {
+	for _, pattern := range patterns {
+		if strings.Contains(userAgent, pattern[0]) {
+			return pattern[1]
+		}
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package application
 import (
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
@@ -10,29 +11,56 @@ import (
 	"github.com/Joe5451/go-oauth2-server/internal/config"
 	"github.com/Joe5451/go-oauth2-server/internal/domain"
 	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
-	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/oauth2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultReauthWindow = 5 * time.Minute
+	linkTokenTTL        = 5 * time.Minute
 )
 
 type UserService struct {
-	userRepo out.UserRepository
+	userRepo          out.UserRepository
+	linkTokenRepo     out.SocialLinkTokenRepository
+	emailVerification in.EmailVerificationUsecase
+	mailer            out.Mailer
+	protection        *LoginProtectionService
+	passwordPolicy    *PasswordPolicy
+	hasher            out.PasswordHasher
 }
 
-func NewUserService(userRepo out.UserRepository) *UserService {
+func NewUserService(
+	userRepo out.UserRepository,
+	linkTokenRepo out.SocialLinkTokenRepository,
+	emailVerification in.EmailVerificationUsecase,
+	mailer out.Mailer,
+	protection *LoginProtectionService,
+	passwordPolicy *PasswordPolicy,
+	hasher out.PasswordHasher,
+) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:          userRepo,
+		linkTokenRepo:     linkTokenRepo,
+		emailVerification: emailVerification,
+		mailer:            mailer,
+		protection:        protection,
+		passwordPolicy:    passwordPolicy,
+		hasher:            hasher,
 	}
 }
 
 func (u *UserService) Register(req in.RegisterUserRequest) error {
-	password, err := u.hashPassword(req.Password)
+	if err := u.passwordPolicy.Check(req.Password, domain.User{Email: &req.Email, Name: req.Name}); err != nil {
+		return err
+	}
+
+	password, err := u.hasher.Hash(req.Password)
 	if err != nil {
 		return err
 	}
 
-	_, err = u.userRepo.CreateUser(domain.User{
-		Email:    req.Email,
+	user, err := u.userRepo.CreateUser(domain.User{
+		Email:    &req.Email,
 		Password: password,
 		Name:     req.Name,
 	})
@@ -40,45 +68,89 @@ func (u *UserService) Register(req in.RegisterUserRequest) error {
 		return err
 	}
 
+	// The account already exists at this point, so a mail failure must not
+	// fail the registration; the user can ask for the link to be resent.
+	if err := u.emailVerification.SendVerificationEmail(user); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	return nil
 }
 
-func (u *UserService) hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	return string(bytes), err
-}
+// AuthenticateUser checks the password, tracking failures per email and per
+// client IP. Unknown emails count as failures too so that a lockout does not
+// reveal whether an email is registered.
+func (u *UserService) AuthenticateUser(email, password, ip string) (domain.User, error) {
+	account := emailAccount(email)
+	if err := u.protection.check(scopeLogin, account, ip); err != nil {
+		return domain.User{}, err
+	}
 
-func (u *UserService) AuthenticateUser(email, password string) (domain.User, error) {
 	user, err := u.userRepo.GetUserByEmail(email)
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
+			if err := u.protection.recordFailure(scopeLogin, account, ip, nil); err != nil {
+				return domain.User{}, err
+			}
 			return domain.User{}, domain.ErrInvalidCredentials
 		}
 		return domain.User{}, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	ok, err := verifyPassword(u.hasher, user, password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if !ok {
+		if err := u.protection.recordFailure(scopeLogin, account, ip, &user); err != nil {
+			return domain.User{}, err
+		}
 		return domain.User{}, domain.ErrInvalidCredentials
 	}
 
+	if err := u.protection.reset(scopeLogin, account); err != nil {
+		return domain.User{}, err
+	}
+
+	// The login already succeeded, so failing to upgrade the hash is only
+	// logged; it is retried on the next login.
+	if err := u.rehashPassword(user, password); err != nil {
+		log.Printf("failed to rehash password of user %d: %v", user.ID, err)
+	}
+
+	if config.AppConfig.EmailVerificationRequired && user.EmailVerifiedAt == nil {
+		return domain.User{}, domain.ErrEmailNotVerified
+	}
+
+	if err := checkDeletedUser(user); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.User{}, domain.ErrInvalidCredentials
+		}
+		return domain.User{}, err
+	}
+
 	return user, nil
 }
 
-func (u *UserService) SocialAuthUrl(provider socialproviders.SocialProvider, state, redirectUri string) (string, error) {
+func (u *UserService) SocialAuthUrl(provider socialproviders.SocialProvider, redirectUri string, params socialproviders.AuthParams) (string, error) {
 	if provider == nil {
 		return "", domain.ErrInvalidProvider
 	}
 
-	config := provider.NewOauth2Config(redirectUri)
-	return config.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+	return provider.AuthCodeURL(redirectUri, params), nil
 }
 
-func (u *UserService) AuthenticateSocialUser(provider socialproviders.SocialProvider, authorizationCode, redirectUri string) (in.AuthSocialUserResult, error) {
+func (u *UserService) AuthenticateSocialUser(
+	provider socialproviders.SocialProvider,
+	authorizationCode string,
+	redirectUri string,
+	params socialproviders.AuthParams,
+) (in.AuthSocialUserResult, error) {
 	if provider == nil {
 		return in.AuthSocialUserResult{}, domain.ErrInvalidProvider
 	}
 
-	socialUser, err := provider.GetUserInformationByAuthorizationCode(authorizationCode, redirectUri)
+	socialUser, err := provider.GetUserInformationByAuthorizationCode(authorizationCode, redirectUri, params)
 	if err != nil {
 		return in.AuthSocialUserResult{}, err
 	}
@@ -102,7 +174,8 @@ func (u *UserService) updateOrCreateSocialAccount(
 	socialAccount, err := u.userRepo.UpdateOrCreateSocialAccount(domain.SocialAccount{
 		Provider:       provider.ProviderName(),
 		ProviderUserID: socialUser.ProviderUserID,
-		Email:          &socialUser.Email,
+		Email:          nullableString(socialUser.Email),
+		EmailVerified:  socialUser.EmailVerified,
 		Name:           &socialUser.Name,
 		Avatar:         &socialUser.Avatar,
 	})
@@ -117,10 +190,28 @@ func (u *UserService) authenticateLinkedUser(userID int64) (in.AuthSocialUserRes
 	if err != nil {
 		return in.AuthSocialUserResult{}, fmt.Errorf("unable to retrieve user associated with social account: %w", err)
 	}
+
+	if err := checkDeletedUser(user); err != nil {
+		return in.AuthSocialUserResult{}, err
+	}
+
 	return in.AuthSocialUserResult{Status: in.AuthSuccess, User: user}, nil
 }
 
+// handleUnlinkedSocialAccount signs up a new user from the social account, or
+// asks the existing user with the same email to confirm the link. Emails the
+// provider has not verified are never matched against existing users.
 func (u *UserService) handleUnlinkedSocialAccount(socialAccount *domain.SocialAccount) (in.AuthSocialUserResult, error) {
+	if socialAccount.Email == nil {
+		// Without an email there is nothing to match on; the social identity
+		// alone becomes the user's login.
+		user, err := u.createUserBySocialAccount(*socialAccount)
+		if err != nil {
+			return in.AuthSocialUserResult{}, fmt.Errorf("failed to create new user for social account without email: %w", err)
+		}
+		return in.AuthSocialUserResult{Status: in.AuthSuccess, User: user}, nil
+	}
+
 	user, err := u.userRepo.GetUserByEmail(*socialAccount.Email)
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
@@ -133,6 +224,16 @@ func (u *UserService) handleUnlinkedSocialAccount(socialAccount *domain.SocialAc
 		return in.AuthSocialUserResult{}, err
 	}
 
+	if !socialAccount.EmailVerified {
+		return in.AuthSocialUserResult{}, domain.ErrUnverifiedSocialEmail
+	}
+
+	// Nobody has proven ownership of the existing account's email, so it may
+	// have been registered by someone else; refuse to offer linking into it.
+	if config.AppConfig.EmailVerificationRequired && user.EmailVerifiedAt == nil {
+		return in.AuthSocialUserResult{}, domain.ErrDuplicateEmail
+	}
+
 	linkToken, err := u.generateLinkToken(user, socialAccount.ID)
 	if err != nil {
 		return in.AuthSocialUserResult{}, fmt.Errorf("failed to generate social account link token: %w", err)
@@ -145,12 +246,26 @@ func (u *UserService) handleUnlinkedSocialAccount(socialAccount *domain.SocialAc
 	}, nil
 }
 
+// createUserBySocialAccount signs up a user from the social account. An email
+// the provider has not verified is only kept pending, and a verification link
+// is mailed to it, until the user proves they own it.
 func (u *UserService) createUserBySocialAccount(account domain.SocialAccount) (domain.User, error) {
-	user, err := u.userRepo.CreateUser(domain.User{
-		Email:  *account.Email,
+	newUser := domain.User{
 		Name:   *account.Name,
 		Avatar: account.Avatar,
-	})
+	}
+
+	if account.Email != nil {
+		if account.EmailVerified {
+			now := time.Now()
+			newUser.Email = account.Email
+			newUser.EmailVerifiedAt = &now
+		} else {
+			newUser.PendingEmail = account.Email
+		}
+	}
+
+	user, err := u.userRepo.CreateUser(newUser)
 	if err != nil {
 		return domain.User{}, fmt.Errorf("failed to create user for social account: %w", err)
 	}
@@ -159,87 +274,136 @@ func (u *UserService) createUserBySocialAccount(account domain.SocialAccount) (d
 	if err != nil {
 		return domain.User{}, err
 	}
+
+	if user.PendingEmail != nil {
+		pending := user
+		pending.Email = user.PendingEmail
+		if err := u.emailVerification.SendVerificationEmail(pending); err != nil {
+			log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
 	return user, nil
 }
 
+// generateLinkToken issues an opaque, single-use token that links the pending
+// social account to the user once they confirm their identity.
 func (u *UserService) generateLinkToken(user domain.User, socialAccountID int64) (string, error) {
-	expirationTime := time.Now().Add(5 * time.Minute)
-
-	claims := in.LinkTokenClaims{
-		UserID:               user.ID,
-		SocialAccountID:      socialAccountID,
-		LinkedSocialAccounts: user.SocialAccounts,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JwtSecret))
+	_, err = u.linkTokenRepo.CreateSocialLinkToken(domain.SocialLinkToken{
+		ID:              uuid.New().String(),
+		TokenHash:       hashToken(token),
+		UserID:          user.ID,
+		SocialAccountID: socialAccountID,
+		ExpiresAt:       time.Now().Add(linkTokenTTL),
+	})
 	if err != nil {
 		return "", err
 	}
+
 	return token, nil
 }
 
+// LinkUserWithSocialAccount links the pending social account of the link
+// token to its user. The user confirms their identity by signing in with one
+// of the social accounts already linked to them, and the token is consumed so
+// it cannot be replayed.
 func (u *UserService) LinkUserWithSocialAccount(
 	provider socialproviders.SocialProvider,
 	authCode string,
 	linkToken string,
 	redirectUri string,
+	params socialproviders.AuthParams,
 ) (domain.User, error) {
 	if provider == nil {
 		return domain.User{}, domain.ErrInvalidProvider
 	}
 
-	claims, err := u.ValidateLinkToken(linkToken)
+	link, err := u.validLinkToken(linkToken)
 	if err != nil {
-		return domain.User{}, fmt.Errorf("%w: %v", domain.ErrInvalidLinkToken, err.Error())
+		return domain.User{}, err
 	}
-	userID, socialAccountID := claims.UserID, claims.SocialAccountID
 
-	socialUser, err := provider.GetUserInformationByAuthorizationCode(authCode, redirectUri)
+	socialUser, err := provider.GetUserInformationByAuthorizationCode(authCode, redirectUri, params)
 	if err != nil {
 		return domain.User{}, err
 	}
 
-	socialAccount, err := u.userRepo.GetSocialAccountByProviderUserID(socialUser.ProviderUserID)
+	socialAccount, err := u.userRepo.GetSocialAccountByProviderUserID(provider.ProviderName(), socialUser.ProviderUserID)
 	if err != nil {
+		if errors.Is(err, domain.ErrSocialAccountNotFound) {
+			return domain.User{}, domain.ErrMismatchedLinkedUser
+		}
 		return domain.User{}, err
 	}
 
-	if *socialAccount.UserID != userID {
+	if socialAccount.UserID == nil || *socialAccount.UserID != link.UserID {
 		return domain.User{}, domain.ErrMismatchedLinkedUser
 	}
 
-	err = u.userRepo.UpdateSocialAccountUserID(socialAccountID, userID)
+	if err := u.linkTokenRepo.ConsumeSocialLinkToken(link.ID); err != nil {
+		return domain.User{}, err
+	}
+
+	if err := u.userRepo.UpdateSocialAccountUserID(link.SocialAccountID, link.UserID); err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := u.GetUser(link.UserID)
 	if err != nil {
-		return domain.User{}, fmt.Errorf("failed to link account: %w", err)
+		return domain.User{}, err
+	}
+
+	if err := checkDeletedUser(user); err != nil {
+		return domain.User{}, err
 	}
 
-	return u.GetUser(userID)
+	return user, nil
 }
 
-func (u *UserService) ValidateLinkToken(linkToken string) (in.LinkTokenClaims, error) {
-	secretKey := []byte(config.AppConfig.JwtSecret)
+// ValidateLinkToken checks the link token without consuming it and returns
+// the social accounts the user can confirm the link with.
+func (u *UserService) ValidateLinkToken(linkToken string) (in.PendingLink, error) {
+	link, err := u.validLinkToken(linkToken)
+	if err != nil {
+		return in.PendingLink{}, err
+	}
 
-	var claims in.LinkTokenClaims
+	socialAccounts, err := u.userRepo.ListSocialAccounts(link.UserID)
+	if err != nil {
+		return in.PendingLink{}, err
+	}
 
-	_, err := jwt.ParseWithClaims(linkToken, &claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Method)
-		}
-		return secretKey, nil
-	})
+	return in.PendingLink{LinkedSocialAccounts: socialAccounts}, nil
+}
 
+func (u *UserService) validLinkToken(linkToken string) (domain.SocialLinkToken, error) {
+	if linkToken == "" {
+		return domain.SocialLinkToken{}, domain.ErrInvalidLinkToken
+	}
+
+	link, err := u.linkTokenRepo.GetSocialLinkToken(hashToken(linkToken))
 	if err != nil {
-		return in.LinkTokenClaims{}, err
+		if errors.Is(err, domain.ErrSocialLinkTokenNotFound) {
+			return domain.SocialLinkToken{}, domain.ErrInvalidLinkToken
+		}
+		return domain.SocialLinkToken{}, err
 	}
 
-	if time.Unix(claims.ExpiresAt, 0).Before(time.Now()) {
-		return in.LinkTokenClaims{}, fmt.Errorf("token has expired")
+	if link.ConsumedAt != nil || time.Now().After(link.ExpiresAt) {
+		return domain.SocialLinkToken{}, domain.ErrInvalidLinkToken
 	}
 
-	return claims, nil
+	return link, nil
+}
+
+// GetLoginMethods returns the ways the user can sign in.
+func (u *UserService) GetLoginMethods(userID int64) (domain.LoginMethods, error) {
+	return u.userRepo.GetLoginMethods(userID)
 }
 
 func (u *UserService) GetUser(userID int64) (domain.User, error) {
@@ -250,9 +414,274 @@ func (u *UserService) GetUser(userID int64) (domain.User, error) {
 	return user, nil
 }
 
-func (u *UserService) UpdateUser(userID int64, user domain.User) error {
-	err := u.userRepo.UpdateUser(userID, user)
-	return err
+// IsSessionRevoked reports whether a session authenticated at authTime has
+// been revoked, either explicitly or because the user no longer exists or is
+// pending deletion.
+func (u *UserService) IsSessionRevoked(userID int64, authTime time.Time) (bool, error) {
+	revocation, err := u.userRepo.GetSessionRevocation(userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	// A user pending deletion is signed out everywhere; signing in again
+	// restores them before the new session starts.
+	if revocation.DeletedAt != nil {
+		return true, nil
+	}
+
+	if revocation.SessionsRevokedAt == nil {
+		return false, nil
+	}
+
+	// auth_time is kept in milliseconds, so the revocation is compared at the
+	// same precision. The session restarted right after a revocation, e.g. by
+	// a password change, may share its millisecond and must stay valid.
+	return authTime.Before(revocation.SessionsRevokedAt.Truncate(time.Millisecond)), nil
+}
+
+// CheckRecentAuth returns ErrReauthRequired when a session authenticated at
+// authTime is too old for sensitive operations.
+func (u *UserService) CheckRecentAuth(authTime time.Time) error {
+	return checkRecentAuth(authTime)
+}
+
+// Reauthenticate confirms the password of the signed-in user before a
+// sensitive operation, tracking failures per user and per client IP.
+func (u *UserService) Reauthenticate(userID int64, password, ip string) error {
+	account := userAccount(userID)
+	if err := u.protection.check(scopeReauth, account, ip); err != nil {
+		return err
+	}
+
+	user, err := u.userRepo.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := verifyPassword(u.hasher, user, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := u.protection.recordFailure(scopeReauth, account, ip, &user); err != nil {
+			return err
+		}
+		return domain.ErrIncorrectPassword
+	}
+
+	return u.protection.reset(scopeReauth, account)
+}
+
+// ReauthenticateSocialUser confirms the identity of the signed-in user with a
+// fresh login to one of their linked social accounts.
+func (u *UserService) ReauthenticateSocialUser(
+	userID int64,
+	provider socialproviders.SocialProvider,
+	authorizationCode string,
+	redirectUri string,
+	params socialproviders.AuthParams,
+) error {
+	if provider == nil {
+		return domain.ErrInvalidProvider
+	}
+
+	socialUser, err := provider.GetUserInformationByAuthorizationCode(authorizationCode, redirectUri, params)
+	if err != nil {
+		return err
+	}
+
+	socialAccount, err := u.userRepo.GetSocialAccountByProviderUserID(provider.ProviderName(), socialUser.ProviderUserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrSocialAccountNotFound) {
+			return domain.ErrMismatchedLinkedUser
+		}
+		return err
+	}
+
+	if socialAccount.UserID == nil || *socialAccount.UserID != userID {
+		return domain.ErrMismatchedLinkedUser
+	}
+
+	return nil
+}
+
+// UpdateUser applies the profile changes unless the user was modified after
+// req.UpdatedAt. A new email only replaces the current one once it has been
+// verified, so it is kept as pending and a verification link is sent to it.
+// Changing the email requires a recent authentication.
+func (u *UserService) UpdateUser(userID int64, req in.UpdateUserRequest) (domain.User, error) {
+	user, err := u.userRepo.GetUser(userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if !user.UpdatedAt.Equal(req.UpdatedAt) {
+		return domain.User{}, domain.ErrUserModified
+	}
+
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		switch {
+		case user.Email != nil && *user.Email == *req.Email:
+			// Changing back to the current email cancels a pending change.
+			user.PendingEmail = nil
+		case user.PendingEmail == nil || *user.PendingEmail != *req.Email:
+			if err := checkRecentAuth(req.AuthTime); err != nil {
+				return domain.User{}, err
+			}
+			if _, err := u.userRepo.GetUserByEmail(*req.Email); err == nil {
+				return domain.User{}, domain.ErrDuplicateEmail
+			} else if !errors.Is(err, domain.ErrUserNotFound) {
+				return domain.User{}, err
+			}
+			user.PendingEmail = req.Email
+			emailChanged = true
+		}
+	}
+
+	user, err = u.userRepo.UpdateUser(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	if emailChanged {
+		pending := user
+		pending.Email = user.PendingEmail
+		if err := u.emailVerification.SendVerificationEmail(pending); err != nil {
+			log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the user's password, or sets one for social-only
+// users. Users with a password must confirm it; users without one must have
+// authenticated recently instead. All sessions authenticated before the change
+// are revoked.
+func (u *UserService) ChangePassword(userID int64, currentPassword, newPassword string, authTime time.Time, ip string) error {
+	user, err := u.userRepo.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.confirmIdentity(user, currentPassword, authTime, ip); err != nil {
+		return err
+	}
+
+	if err := u.passwordPolicy.Check(newPassword, user); err != nil {
+		return err
+	}
+
+	hashedPassword, err := u.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := u.userRepo.UpdateUserPassword(userID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := u.userRepo.RevokeUserSessions(userID, time.Now()); err != nil {
+		return err
+	}
+
+	// The password has already changed, so a mail failure is only logged.
+	if err := u.sendPasswordChangedNotification(user); err != nil {
+		log.Printf("failed to send password changed notification to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+func (u *UserService) sendPasswordChangedNotification(user domain.User) error {
+	if user.Email == nil {
+		return nil
+	}
+
+	return u.mailer.Send(out.Mail{
+		To:       *user.Email,
+		Subject:  "Your password was changed",
+		Template: "password_changed",
+		Data: map[string]any{
+			"Name":        user.Name,
+			"PasswordSet": user.Password == "", // The user had no password before
+			"ChangedAt":   time.Now().UTC().Format(time.RFC1123),
+		},
+	})
+}
+
+// DeleteUser schedules the user for deletion after the grace period and signs
+// them out everywhere. Signing in again before then restores the account.
+func (u *UserService) DeleteUser(userID int64, password string, authTime time.Time, ip string) (time.Time, error) {
+	user, err := u.userRepo.GetUser(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := u.confirmIdentity(user, password, authTime, ip); err != nil {
+		return time.Time{}, err
+	}
+
+	deletedAt := time.Now()
+	if err := u.userRepo.SoftDeleteUser(userID, deletedAt); err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := deletedAt.Add(deletionGracePeriod())
+
+	if user.Email != nil {
+		err := u.mailer.Send(out.Mail{
+			To:       *user.Email,
+			Subject:  "Your account will be deleted",
+			Template: "account_deleted",
+			Data: map[string]any{
+				"Name":    user.Name,
+				"PurgeAt": purgeAt.UTC().Format(time.RFC1123),
+			},
+		})
+		if err != nil {
+			log.Printf("failed to send account deletion notice to user %d: %v", user.ID, err)
+		}
+	}
+
+	return purgeAt, nil
+}
+
+// checkDeletedUser treats users past the deletion grace period as already
+// gone. Users within it may go on signing in; the deletion is only cancelled
+// once the sign-in completes, see SessionService.StartSession.
+func checkDeletedUser(user domain.User) error {
+	if user.DeletedAt != nil && time.Since(*user.DeletedAt) > deletionGracePeriod() {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// restoreDeletedUser cancels a pending deletion when the user signs in during
+// the grace period. Users past it are treated as already gone.
+func restoreDeletedUser(userRepo out.UserRepository, user *domain.User) error {
+	if user.DeletedAt == nil {
+		return nil
+	}
+
+	if err := checkDeletedUser(*user); err != nil {
+		return err
+	}
+
+	if err := userRepo.RestoreUser(user.ID); err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	return nil
 }
 
 func (u *UserService) UpdateUserAvatar(userID int64, avatarUrl string) error {
@@ -260,12 +689,18 @@ func (u *UserService) UpdateUserAvatar(userID int64, avatarUrl string) error {
 	return err
 }
 
-func (u *UserService) LinkSocialAccount(userID int64, provider socialproviders.SocialProvider, authCode, redirectUri string) error {
+func (u *UserService) LinkSocialAccount(
+	userID int64,
+	provider socialproviders.SocialProvider,
+	authCode string,
+	redirectUri string,
+	params socialproviders.AuthParams,
+) error {
 	if provider == nil {
 		return domain.ErrInvalidProvider
 	}
 
-	socialUser, err := provider.GetUserInformationByAuthorizationCode(authCode, redirectUri)
+	socialUser, err := provider.GetUserInformationByAuthorizationCode(authCode, redirectUri, params)
 	if err != nil {
 		return err
 	}
@@ -287,14 +722,78 @@ func (u *UserService) LinkSocialAccount(userID int64, provider socialproviders.S
 	return nil
 }
 
+// UnlinkSocialAccount unlinks the user's accounts of the provider, unless they
+// are the last way left for the user to sign in.
 func (u *UserService) UnlinkSocialAccount(userID int64, provider socialproviders.SocialProvider) error {
 	if provider == nil {
 		return domain.ErrInvalidProvider
 	}
 
-	if err := u.userRepo.UnlinkSocialAccount(userID, provider.ProviderName()); err != nil {
+	// The repository checks and unlinks in one transaction, so concurrent
+	// removals cannot both pass the check.
+	return u.userRepo.UnlinkSocialAccount(userID, provider.ProviderName())
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// rehashPassword stores a new hash of the password when the stored one was
+// made with another algorithm or other parameters than the current ones.
+func (u *UserService) rehashPassword(user domain.User, password string) error {
+	if !u.hasher.NeedsRehash(user.Password) {
+		return nil
+	}
+
+	hashedPassword, err := u.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return u.userRepo.RehashUserPassword(user.ID, user.Password, hashedPassword)
+}
+
+// confirmIdentity checks the password of users who have one, and requires
+// users without one to have authenticated recently instead. Password failures
+// count against the same per-user and per-IP limits as Reauthenticate, so a
+// hijacked session cannot guess the password through these operations.
+func (u *UserService) confirmIdentity(user domain.User, password string, authTime time.Time, ip string) error {
+	if user.Password == "" {
+		return checkRecentAuth(authTime)
+	}
+
+	account := userAccount(user.ID)
+	if err := u.protection.check(scopeReauth, account, ip); err != nil {
 		return err
 	}
 
+	ok, err := verifyPassword(u.hasher, user, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := u.protection.recordFailure(scopeReauth, account, ip, &user); err != nil {
+			return err
+		}
+		return domain.ErrIncorrectPassword
+	}
+
+	return u.protection.reset(scopeReauth, account)
+}
+
+func checkRecentAuth(authTime time.Time) error {
+	if time.Since(authTime) > reauthWindow() {
+		return domain.ErrReauthRequired
+	}
 	return nil
 }
+
+func reauthWindow() time.Duration {
+	if config.AppConfig.ReauthWindow > 0 {
+		return config.AppConfig.ReauthWindow
+	}
+	return defaultReauthWindow
+}
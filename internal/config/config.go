@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -27,12 +28,85 @@ type Config struct {
 	TwitchOauth2ClientID     string `mapstructure:"TWITCH_OAUTH2_CLIENT_ID"`
 	TwitchOauth2ClientSecret string `mapstructure:"TWITCH_OAUTH2_CLIENT_SECRET"`
 
+	OAuthStateTTL time.Duration `mapstructure:"OAUTH_STATE_TTL"`
+
+	SocialRedirectURIs        []string `mapstructure:"SOCIAL_REDIRECT_URIS"`
+	SocialRedirectURIPatterns []string `mapstructure:"SOCIAL_REDIRECT_URI_PATTERNS"`
+	SocialDefaultRedirectURI  string   `mapstructure:"SOCIAL_DEFAULT_REDIRECT_URI"`
+
+	AppBaseUrl           string `mapstructure:"APP_BASE_URL"`
+	SocialPostLoginUrl   string `mapstructure:"SOCIAL_POST_LOGIN_URL"`
+	SocialLinkConfirmUrl string `mapstructure:"SOCIAL_LINK_CONFIRM_URL"`
+	SocialErrorUrl       string `mapstructure:"SOCIAL_ERROR_URL"`
+
 	JwtSecret string `mapstructure:"JWT_SECRET_KEY"`
 
 	CSRFSecret string `mapstructure:"CSRF_SECRET_KEY"`
 	CSRFSecure bool   `mapstructure:"CSRF_SECURE"`
 
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
 	UploadBaseUrl string `mapstructure:"UPLOAD_BASE_URL"`
+
+	MailFrom        string `mapstructure:"MAIL_FROM"`
+	MailDriver      string `mapstructure:"MAIL_DRIVER"`
+	MailTemplateDir string `mapstructure:"MAIL_TEMPLATE_DIR"`
+	MailFileDir     string `mapstructure:"MAIL_FILE_DIR"`
+
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     string `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	EmailVerificationRequired       bool          `mapstructure:"EMAIL_VERIFICATION_REQUIRED"`
+	EmailVerificationTTL            time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	EmailVerificationResendInterval time.Duration `mapstructure:"EMAIL_VERIFICATION_RESEND_INTERVAL"`
+	EmailVerificationHourlyLimit    int           `mapstructure:"EMAIL_VERIFICATION_HOURLY_LIMIT"`
+
+	PasswordResetTTL            time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	PasswordResetResendInterval time.Duration `mapstructure:"PASSWORD_RESET_RESEND_INTERVAL"`
+
+	MagicLinkTTL            time.Duration `mapstructure:"MAGIC_LINK_TTL"`
+	MagicLinkResendInterval time.Duration `mapstructure:"MAGIC_LINK_RESEND_INTERVAL"`
+
+	ReauthWindow time.Duration `mapstructure:"REAUTH_WINDOW"`
+
+	SessionStore            string        `mapstructure:"SESSION_STORE"`
+	SessionSecret           string        `mapstructure:"SESSION_SECRET"`
+	SessionIdleTimeout      time.Duration `mapstructure:"SESSION_IDLE_TIMEOUT"`
+	SessionAbsoluteTimeout  time.Duration `mapstructure:"SESSION_ABSOLUTE_TIMEOUT"`
+	SessionRememberDuration time.Duration `mapstructure:"SESSION_REMEMBER_DURATION"`
+	SessionCookieSecure     bool          `mapstructure:"SESSION_COOKIE_SECURE"`
+
+	PasswordMinLength           int      `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength           int      `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordMinCharacterClasses int      `mapstructure:"PASSWORD_MIN_CHARACTER_CLASSES"`
+	PasswordBannedList          []string `mapstructure:"PASSWORD_BANNED_LIST"`
+	BreachedPasswordDir         string   `mapstructure:"BREACHED_PASSWORD_DIR"`
+
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost            int    `mapstructure:"BCRYPT_COST"`
+	Argon2Memory          int    `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations      int    `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism     int    `mapstructure:"ARGON2_PARALLELISM"`
+
+	LoginLockoutThreshold int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginFailureWindow    time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginIPFailureLimit   int           `mapstructure:"LOGIN_IP_FAILURE_LIMIT"`
+	LoginAttemptStore     string        `mapstructure:"LOGIN_ATTEMPT_STORE"`
+
+	AccountDeletionGracePeriod time.Duration `mapstructure:"ACCOUNT_DELETION_GRACE_PERIOD"`
+	AccountPurgeInterval       time.Duration `mapstructure:"ACCOUNT_PURGE_INTERVAL"`
+
+	DataExportDir string        `mapstructure:"DATA_EXPORT_DIR"`
+	DataExportTTL time.Duration `mapstructure:"DATA_EXPORT_TTL"`
+
+	TOTPIssuer string `mapstructure:"TOTP_ISSUER"`
+
+	WebAuthnRPID          string   `mapstructure:"WEBAUTHN_RP_ID"`
+	WebAuthnRPDisplayName string   `mapstructure:"WEBAUTHN_RP_DISPLAY_NAME"`
+	WebAuthnRPOrigins     []string `mapstructure:"WEBAUTHN_RP_ORIGINS"`
 }
 
 var AppConfig Config
@@ -7,10 +7,13 @@ import (
 	"github.com/Joe5451/go-oauth2-server/internal/config"
 	"github.com/pkg/errors"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPostgresDB() (*pgx.Conn, error) {
+// NewPostgresDB returns a connection pool rather than a single connection, as
+// requests and background workers such as the data export run concurrently.
+// The returned func closes the pool.
+func NewPostgresDB() (*pgxpool.Pool, func(), error) {
 	databaseUrl := fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
 		config.AppConfig.DBUser,
@@ -20,10 +23,16 @@ func NewPostgresDB() (*pgx.Conn, error) {
 		config.AppConfig.DBName,
 	)
 
-	conn, err := pgx.Connect(context.Background(), databaseUrl)
+	db, err := pgxpool.New(context.Background(), databaseUrl)
 	if err != nil {
-		return nil, errors.Wrap(err, "Unable to connect to database")
+		return nil, nil, errors.Wrap(err, "Unable to connect to database")
 	}
 
-	return conn, nil
+	// The pool connects lazily, so check the database is reachable at startup.
+	if err := db.Ping(context.Background()); err != nil {
+		db.Close()
+		return nil, nil, errors.Wrap(err, "Unable to connect to database")
+	}
+
+	return db, db.Close, nil
 }
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Joe5451/go-oauth2-server/internal/config"
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+func NewRedisPool() (*redis.Pool, error) {
+	address := fmt.Sprintf("%s:%s", config.AppConfig.RedisHost, config.AppConfig.RedisPort)
+
+	pool := &redis.Pool{
+		MaxIdle: 10,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", address, redis.DialPassword(config.AppConfig.RedisPassword))
+		},
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("PING"); err != nil {
+		return nil, errors.Wrap(err, "Unable to connect to redis")
+	}
+
+	return pool, nil
+}
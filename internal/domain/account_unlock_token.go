@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"time"
+)
+
+// AccountUnlockToken lifts the lockouts of a user, once. Only the hash of the
+// opaque token mailed to the user is stored.
+type AccountUnlockToken struct {
+	ID         string
+	TokenHash  string
+	UserID     int64
+	ExpiresAt  time.Time // End of the lockout the token was mailed for
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+)
+
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending" // The archive is being generated
+	DataExportReady   DataExportStatus = "ready"   // The archive can be downloaded until it expires
+	DataExportFailed  DataExportStatus = "failed"  // Generating the archive failed; a new export can be requested
+)
+
+type DataExport struct {
+	ID          string
+	UserID      int64
+	Status      DataExportStatus
+	FilePath    *string
+	ExpiresAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"time"
+)
+
+type EmailVerification struct {
+	ID        string
+	UserID    int64
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
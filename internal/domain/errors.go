@@ -1,6 +1,9 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrInvalidProvider              = errors.New("invalid provider")
@@ -10,7 +13,69 @@ var (
 	ErrDuplicateEmail               = errors.New("duplicate email found")
 	ErrSocialUserFetch              = errors.New("failed to fetch user information from social provider")
 	ErrInvalidLinkToken             = errors.New("invalid link token")
+	ErrSocialLinkTokenNotFound      = errors.New("link token not found")
 	ErrMismatchedLinkedUser         = errors.New("mismatched linked user")
 	ErrSocialAccountAlreadyLinked   = errors.New("the social account has already been linked to a user")
 	ErrSocialAccountAlreadyUnlinked = errors.New("social account is not linked or has already been unlinked")
+	ErrUnverifiedSocialEmail        = errors.New("the social account email has not been verified by the provider")
+	ErrEmailNotVerified             = errors.New("email address has not been verified")
+	ErrInvalidVerificationToken     = errors.New("invalid or expired email verification token")
+	ErrEmailVerificationNotFound    = errors.New("email verification not found")
+	ErrInvalidPasswordResetToken    = errors.New("invalid or expired password reset token")
+	ErrPasswordResetNotFound        = errors.New("password reset not found")
+	ErrIncorrectPassword            = errors.New("the current password is incorrect")
+	ErrReauthRequired               = errors.New("recent authentication is required")
+	ErrUserModified                 = errors.New("the user has been modified since it was read")
+	ErrDataExportNotFound           = errors.New("data export not found")
+	ErrInvalidExportToken           = errors.New("invalid or expired data export link")
+	ErrTOTPNotEnrolled              = errors.New("two-factor authentication has not been set up")
+	ErrTOTPAlreadyEnabled           = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFACode               = errors.New("invalid two-factor authentication code")
+	ErrWebAuthnCredentialNotFound   = errors.New("passkey not found")
+	ErrWebAuthnFailed               = errors.New("passkey verification failed")
+	ErrInvalidMagicLink             = errors.New("invalid or expired login link")
+	ErrMagicLinkNotFound            = errors.New("login link not found")
+	ErrMagicLinkDeviceMismatch      = errors.New("the login link was requested from another browser")
+	ErrAccountLocked                = errors.New("the account is temporarily locked after too many failed attempts")
+	ErrTooManyAttempts              = errors.New("too many failed attempts, try again later")
+	ErrInvalidUnlockToken           = errors.New("invalid or expired account unlock token")
+	ErrAccountUnlockTokenNotFound   = errors.New("account unlock token not found")
+	ErrPasswordPolicy               = errors.New("the password does not meet the password policy")
+	ErrSessionNotFound              = errors.New("session not found")
+	ErrLastLoginMethod              = errors.New("the last remaining login method cannot be removed")
 )
+
+// RetryAfterError tells the client when a rejected attempt may be retried.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError describes why the value of a request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries the rejected fields of a request.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"slices"
+)
+
+// LoginMethods are the ways a user can sign in.
+type LoginMethods struct {
+	Password      bool     `json:"password"`
+	EmailVerified bool     `json:"email_verified"` // The user has an email and it is verified
+	Providers     []string `json:"providers"`      // Linked social providers
+	Passkeys      int      `json:"passkeys"`
+}
+
+// Count returns how many login methods the user has. Each linked provider and
+// each passkey counts on its own. The password only counts when the user has
+// a verified email to sign in and reset it with.
+func (m LoginMethods) Count() int {
+	count := len(m.Providers) + m.Passkeys
+	if m.Password && m.EmailVerified {
+		count++
+	}
+	return count
+}
+
+// WithoutProvider returns the login methods left after unlinking the user's
+// accounts of the provider.
+func (m LoginMethods) WithoutProvider(provider string) LoginMethods {
+	m.Providers = slices.DeleteFunc(slices.Clone(m.Providers), func(name string) bool {
+		return name == provider
+	})
+	return m
+}
+
+// WithoutPasskey returns the login methods left after removing a passkey.
+func (m LoginMethods) WithoutPasskey() LoginMethods {
+	m.Passkeys = max(m.Passkeys-1, 0)
+	return m
+}
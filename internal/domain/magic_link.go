@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"time"
+)
+
+type MagicLink struct {
+	ID         string
+	UserID     int64
+	Email      string
+	DeviceHash string // Hash of the device token of the browser that requested the link
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"time"
+)
+
+type PasswordReset struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
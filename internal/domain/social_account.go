@@ -10,6 +10,7 @@ type SocialAccount struct {
 	Provider       string    `json:"provider"`
 	ProviderUserID string    `json:"-"`
 	Email          *string   `json:"email"`
+	EmailVerified  bool      `json:"-"`
 	Name           *string   `json:"name"`
 	Avatar         *string   `json:"avatar"`
 	CreatedAt      time.Time `json:"-"`
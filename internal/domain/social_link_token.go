@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+)
+
+// SocialLinkToken lets the user who signed in with an unlinked social account
+// link it, once, to the existing user with the same email. Only the hash of
+// the opaque token handed to the client is stored.
+type SocialLinkToken struct {
+	ID              string // Random UUID, unrelated to the token
+	TokenHash       string
+	UserID          int64 // Existing user the social account is linked to
+	SocialAccountID int64 // Pending social account
+	ExpiresAt       time.Time
+	ConsumedAt      *time.Time
+	CreatedAt       time.Time
+}
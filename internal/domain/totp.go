@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+)
+
+type TOTP struct {
+	UserID       int64
+	Secret       string
+	EnabledAt    *time.Time // Nil until the enrollment is confirmed with a valid code
+	LastUsedStep int64      // Time step of the last accepted code, to reject replays
+	CreatedAt    time.Time
+}
+
+type RecoveryCode struct {
+	UserID    int64
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
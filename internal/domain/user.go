@@ -5,12 +5,17 @@ import (
 )
 
 type User struct {
-	ID             int64           `json:"-"`
-	Email          string          `json:"email"`
-	Password       string          `json:"-"`
-	Name           string          `json:"name"`
-	Avatar         *string         `json:"avatar"`
-	SocialAccounts []SocialAccount `json:"social_accounts"`
-	CreatedAt      time.Time       `json:"-"`
-	UpdatedAt      time.Time       `json:"-"`
+	ID                int64           `json:"-"`
+	Email             *string         `json:"email"`         // Nil for social-only users whose provider shared no email
+	PendingEmail      *string         `json:"pending_email"` // New email awaiting verification
+	Password          string          `json:"-"`
+	Name              string          `json:"name"`
+	Avatar            *string         `json:"avatar"`
+	SocialAccounts    []SocialAccount `json:"social_accounts"`
+	MFAEnabled        bool            `json:"mfa_enabled"` // Whether a second factor is required to sign in
+	EmailVerifiedAt   *time.Time      `json:"-"`
+	SessionsRevokedAt *time.Time      `json:"-"` // Sessions authenticated before this time are no longer valid
+	DeletedAt         *time.Time      `json:"-"` // Set during the grace period before the user is purged
+	CreatedAt         time.Time       `json:"-"`
+	UpdatedAt         time.Time       `json:"updated_at"` // Version for optimistic concurrency
 }
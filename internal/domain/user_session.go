@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"time"
+)
+
+// UserSession is the index entry of a signed-in browser session.
+type UserSession struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"-"`
+	Device     string    `json:"device"`     // Browser and OS derived from the user agent
+	IPAddress  string    `json:"ip_address"` // Address the session was last seen from
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+
+	// RememberedUntil is set while a remember-me cookie can sign the device
+	// back in after the session itself has expired.
+	RememberedUntil *time.Time `json:"remembered_until"`
+}
+
+// SessionRevocation holds what decides whether the user's signed-in sessions
+// are still valid.
+type SessionRevocation struct {
+	SessionsRevokedAt *time.Time // Sessions authenticated before it are signed out
+	DeletedAt         *time.Time // Set while the user is pending deletion
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+)
+
+// WebAuthnCredential is a passkey registered by a user. It can be used both to
+// sign in without a password and as a second factor.
+type WebAuthnCredential struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"-"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"-"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"-"`
+	Transports      []string   `json:"transports"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"` // Last signature counter reported by the authenticator
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
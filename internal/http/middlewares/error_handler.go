@@ -2,12 +2,13 @@ package middlewares
 
 import (
 	"errors"
+	"math"
 	"net/http"
 	"reflect"
+	"strconv"
 
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/handlers"
 	"github.com/Joe5451/go-oauth2-server/internal/domain"
-	"github.com/Joe5451/go-oauth2-server/internal/socialproviders"
 	"github.com/gin-gonic/gin"
 )
 
@@ -40,6 +41,25 @@ func isType(a, b interface{}) bool {
 	return reflect.TypeOf(a) == reflect.TypeOf(b)
 }
 
+// setRetryAfter sets the Retry-After header, in whole seconds, when the error
+// tells when to retry.
+func setRetryAfter(c *gin.Context, err error) {
+	var retryAfterErr *domain.RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		seconds := int(math.Ceil(retryAfterErr.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	}
+}
+
+// fieldErrors lists the rejected fields of a validation error.
+func fieldErrors(err error) ([]domain.FieldError, bool) {
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Fields, true
+	}
+	return nil, false
+}
+
 type errorMapping struct {
 	fromErrors   []error
 	toStatusCode int
@@ -66,84 +86,24 @@ func Map(err ...error) *errorMapping {
 }
 
 func InitErrorHandler() gin.HandlerFunc {
-	return ErrorHandler(
-		Map(handlers.ErrValidation).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    "VALIDATION_ERROR",
-				"message": err.Error(),
-			})
-		}),
-		Map(handlers.ErrUnauthorized).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    "UNAUTHORIZED",
-				"message": "Requires authentication.",
-			})
-		}),
-		Map(handlers.ErrMissingFile).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    "MISSING_FILE",
-				"message": err.Error(),
-			})
-		}),
-		Map(handlers.ErrInvalidFileFormat).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    "INVALID_FILE_FORMAT",
-				"message": err.Error(),
-			})
-		}),
-		Map(domain.ErrUserNotFound).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    "USER_NOT_FOUND",
-				"message": "The user does not exist.",
-			})
-		}),
-		Map(domain.ErrInvalidCredentials).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    "INVALID_CREDENTIALS",
-				"message": "Incorrect email or password.",
-			})
-		}),
-		Map(domain.ErrDuplicateEmail).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusConflict, gin.H{
-				"code":    "DUPLICATE_EMAIL",
-				"message": "The email is already in use.",
-			})
-		}),
-		Map(socialproviders.ErrInvalidProvider).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    "INVALID_SOCIAL_PROVIDER",
-				"message": err.Error(),
-			})
-		}),
-		Map(domain.ErrInvalidLinkToken).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    "INVALID_LINK_TOKEN",
-				"message": err.Error(),
-			})
-		}),
-		Map(domain.ErrMismatchedLinkedUser).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusConflict, gin.H{
-				"code":    "MISMATCHED_LINKED_USER",
-				"message": "The linked social account belongs to a different user.",
-			})
-		}),
-		Map(domain.ErrSocialAccountAlreadyLinked).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusConflict, gin.H{
-				"code":    "SOCIAL_ACCOUNT_ALREADY_LINKED",
-				"message": "The social account has already been linked to another user.",
-			})
-		}),
-		Map(domain.ErrSocialAccountAlreadyUnlinked).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusConflict, gin.H{
-				"code":    "SOCIAL_ACCOUNT_ALREADY_UNLINKED",
-				"message": "The social account has either not been linked or has already been unlinked.",
-			})
-		}),
-		Map(socialproviders.ErrOAuth2RetrieveError).ToResponse(func(c *gin.Context, err error) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    "OAUTH2_RETRIEVE_ERROR",
-				"message": err.Error(),
-			})
-		}),
-	)
+	errMap := make([]*errorMapping, 0, len(handlers.ErrorCodes))
+	for _, e := range handlers.ErrorCodes {
+		errMap = append(errMap, Map(e.Err).ToResponse(func(c *gin.Context, err error) {
+			setRetryAfter(c, err)
+
+			message := e.Message
+			if message == "" {
+				message = err.Error()
+			}
+			response := gin.H{
+				"code":    e.Code,
+				"message": message,
+			}
+			if fields, ok := fieldErrors(err); ok {
+				response["errors"] = fields
+			}
+			c.JSON(e.Status, response)
+		}))
+	}
+	return ErrorHandler(errMap...)
 }
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/handlers"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionGuard signs out sessions that were authenticated before the user's
+// sessions were revoked, e.g. by a password reset, and lets the session
+// manager enforce the session timeouts and the session index.
+func SessionGuard(usecase in.UserUsecase, sessionManager *handlers.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+
+		if userID, ok := session.Get("user_id").(int64); ok {
+			// Sessions created before auth_time was recorded count as
+			// authenticated at the epoch, so any revocation applies to them.
+			authTime, _ := session.Get("auth_time").(int64)
+
+			revoked, err := usecase.IsSessionRevoked(userID, time.UnixMilli(authTime))
+			if err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+
+			if revoked {
+				session.Clear()
+			}
+		}
+
+		if err := sessionManager.Refresh(c, session); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
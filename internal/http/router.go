@@ -4,27 +4,38 @@ import (
 	"fmt"
 
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/handlers"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/config"
 	"github.com/Joe5451/go-oauth2-server/internal/http/middlewares"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
 )
 
 func NewRouter(
+	userUsecase in.UserUsecase,
+	sessionManager *handlers.SessionManager,
+	store sessions.Store,
 	userHandler *handlers.UserHandler,
+	emailHandler *handlers.EmailHandler,
+	passwordHandler *handlers.PasswordHandler,
+	mfaHandler *handlers.MFAHandler,
+	passkeyHandler *handlers.PasskeyHandler,
+	magicLinkHandler *handlers.MagicLinkHandler,
+	accountHandler *handlers.AccountHandler,
+	sessionHandler *handlers.SessionHandler,
+	dataExportHandler *handlers.DataExportHandler,
+	socialRedirectHandler *handlers.SocialRedirectHandler,
 	templateHandler *handlers.TemplateHandler,
-) *gin.Engine {
+) (*gin.Engine, error) {
 	router := gin.Default()
 
-	// Set up a redis session
-	store, _ := redis.NewStore(
-		10,
-		"tcp",
-		fmt.Sprintf("%s:%s", config.AppConfig.RedisHost, config.AppConfig.RedisPort),
-		config.AppConfig.RedisPassword,
-		[]byte(config.AppConfig.RedisSecret),
-	)
+	// Login lockouts are keyed on ClientIP, so only the configured proxies may
+	// set X-Forwarded-For. None are trusted by default.
+	if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	store.Options(sessionManager.CookieOptions())
 
 	// API
 	router.Static("/uploads", "./uploads")
@@ -37,16 +48,60 @@ func NewRouter(
 		// Set up error handler
 		api.Use(middlewares.InitErrorHandler())
 
+		// Sign out revoked and expired sessions and resume remembered ones
+		api.Use(middlewares.SessionGuard(userUsecase, sessionManager))
+
 		// setup csrf middleware
 		api.Use(middlewares.CSRF())
 		api.Use(middlewares.CSRFToken())
 
+		// Sensitive operations require a recent authentication
+		recentAuth := middlewares.RequireRecentAuth(userUsecase)
+
 		api.GET("/csrf-token", userHandler.CSRFToken)
 		api.POST("/register", userHandler.Register)
 		api.POST("/login", userHandler.LoginWithEmail)
+		api.POST("/login/magic-link", magicLinkHandler.RequestMagicLink)
+		api.POST("/login/magic-link/verify", magicLinkHandler.VerifyMagicLink)
+		api.POST("/login/mfa", mfaHandler.LoginMFA)
+		api.POST("/login/mfa/passkey/begin", passkeyHandler.BeginMFA)
+		api.POST("/login/mfa/passkey/finish", passkeyHandler.FinishMFA)
+		api.POST("/login/passkey/begin", passkeyHandler.BeginLogin)
+		api.POST("/login/passkey/finish", passkeyHandler.FinishLogin)
 		api.POST("/logout", userHandler.Logout)
 		api.GET("/user", userHandler.GetUser)
+		api.PATCH("/user", userHandler.UpdateUser)
+		api.DELETE("/user", recentAuth, userHandler.DeleteUser)
 		api.PATCH("/user/avatar", userHandler.UpdateUserAvatar)
+		api.PUT("/user/password", userHandler.ChangePassword)
+		api.GET("/user/login-methods", userHandler.GetLoginMethods)
+		api.POST("/user/reauth", userHandler.Reauthenticate)
+		api.POST("/user/reauth/mfa", mfaHandler.Reauthenticate)
+		api.GET("/user/reauth/social/:provider/url", userHandler.SocialAuthUrlForReauth)
+		api.POST("/user/reauth/social/:provider", userHandler.ReauthenticateSocial)
+		api.GET("/user/mfa", mfaHandler.GetStatus)
+		api.POST("/user/mfa/totp", mfaHandler.EnrollTOTP)
+		api.POST("/user/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+		api.DELETE("/user/mfa/totp", mfaHandler.DisableTOTP)
+		api.POST("/user/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+		api.GET("/user/passkeys", passkeyHandler.ListPasskeys)
+		api.POST("/user/passkeys/register/begin", passkeyHandler.BeginRegistration)
+		api.POST("/user/passkeys/register/finish", passkeyHandler.FinishRegistration)
+		api.PATCH("/user/passkeys/:id", passkeyHandler.RenamePasskey)
+		api.DELETE("/user/passkeys/:id", passkeyHandler.DeletePasskey)
+		api.GET("/user/sessions", sessionHandler.ListSessions)
+		api.DELETE("/user/sessions", sessionHandler.RevokeOtherSessions)
+		api.DELETE("/user/sessions/:id", sessionHandler.RevokeSession)
+		api.GET("/user/export", dataExportHandler.RequestExport)
+		api.GET("/user/export/download", dataExportHandler.Download)
+
+		api.POST("/email/verify", emailHandler.VerifyEmail)
+		api.POST("/email/verify/resend", emailHandler.ResendVerificationEmail)
+
+		api.POST("/password/forgot", passwordHandler.ForgotPassword)
+		api.POST("/password/reset", passwordHandler.ResetPassword)
+
+		api.POST("/account/unlock", accountHandler.UnlockAccount)
 
 		api.GET("/login/social/:provider", userHandler.SocialAuthURL)
 		api.POST("/login/social/callback", userHandler.SocialAuthCallback)
@@ -54,8 +109,24 @@ func NewRouter(
 		api.GET("/auth/social/:provider/link/url", userHandler.SocialAuthUrlForLinkingExistingUser)
 		api.POST("/auth/social/link", userHandler.LinkUserWithSocialAccount)
 
-		api.POST("/user/link/:provider", userHandler.LinkSocialAccount)
-		api.DELETE("/user/unlink/:provider", userHandler.UnlinkSocialAccount)
+		api.GET("/user/link/:provider/url", recentAuth, userHandler.SocialAuthUrlForConnectingAccount)
+		api.POST("/user/link/:provider", recentAuth, userHandler.LinkSocialAccount)
+		api.DELETE("/user/unlink/:provider", recentAuth, userHandler.UnlinkSocialAccount)
+	}
+
+	// Server-side social login
+	{
+		auth := router.Group("/auth")
+		auth.Use(sessions.Sessions("usersession", store))
+		auth.Use(middlewares.InitErrorHandler())
+
+		// Sign out revoked and expired sessions and resume remembered ones
+		auth.Use(middlewares.SessionGuard(userUsecase, sessionManager))
+
+		auth.GET("/social/:provider", socialRedirectHandler.Login)
+		auth.GET("/social/:provider/link", socialRedirectHandler.Link)
+		auth.GET("/social/:provider/callback", socialRedirectHandler.Callback)
+		auth.GET("/link/confirm", socialRedirectHandler.LinkConfirm)
 	}
 
 	// Template
@@ -64,8 +135,13 @@ func NewRouter(
 	{
 		template := router.Group("/template")
 		template.GET("/login", templateHandler.Login)
+		template.GET("/login/mfa", templateHandler.LoginMFA)
+		template.GET("/login/magic-link", templateHandler.MagicLink)
+		template.GET("/email/verify", templateHandler.VerifyEmail)
+		template.GET("/password/reset", templateHandler.ResetPassword)
+		template.GET("/account/unlock", templateHandler.UnlockAccount)
 		template.GET("/user/social-links", templateHandler.SocialLinks)
 	}
 
-	return router
+	return router, nil
 }
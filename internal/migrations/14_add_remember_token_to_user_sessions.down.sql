@@ -0,0 +1,2 @@
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS remembered_until;
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS remember_token_hash;
@@ -0,0 +1,2 @@
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS remember_token_hash CHAR(64) NULL UNIQUE;
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS remembered_until TIMESTAMPTZ NULL;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS http_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS http_sessions_expires_at_idx ON http_sessions (expires_at);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS social_link_tokens;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS social_link_tokens (
+    id UUID PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    user_id BIGINT NOT NULL,
+    social_account_id BIGINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (social_account_id) REFERENCES social_accounts(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key VARCHAR(255) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NULL,
+    failures_expire_at TIMESTAMPTZ NULL,
+    locked_until TIMESTAMPTZ NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS retired_remember_tokens;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS retired_remember_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    session_id UUID NOT NULL,
+    retired_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES user_sessions(id) ON DELETE CASCADE
+);
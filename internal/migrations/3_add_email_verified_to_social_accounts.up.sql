@@ -0,0 +1 @@
+ALTER TABLE social_accounts ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+UPDATE users SET email = NULL WHERE email = '';
+UPDATE social_accounts SET email = NULL WHERE email = '';
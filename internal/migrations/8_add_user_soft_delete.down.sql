@@ -0,0 +1,6 @@
+ALTER TABLE social_accounts DROP CONSTRAINT IF EXISTS social_accounts_user_id_fkey;
+ALTER TABLE social_accounts ADD CONSTRAINT social_accounts_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+DROP INDEX IF EXISTS users_deleted_at_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
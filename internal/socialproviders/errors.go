@@ -5,4 +5,5 @@ import "errors"
 var (
 	ErrOAuth2RetrieveError = errors.New("OAuth2 retrieve error")
 	ErrInvalidProvider     = errors.New("invalid social provider")
+	ErrInvalidNonce        = errors.New("invalid id_token nonce")
 )
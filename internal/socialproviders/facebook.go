@@ -54,9 +54,13 @@ func (p *FacebookProvider) NewOauth2Config(redirectUri string) *oauth2.Config {
 	return conf
 }
 
-func (p *FacebookProvider) GetUserInformationByAuthorizationCode(code, redirectUri string) (SocialProviderUser, error) {
+func (p *FacebookProvider) AuthCodeURL(redirectUri string, params AuthParams) string {
+	return p.NewOauth2Config(redirectUri).AuthCodeURL(params.State, authCodeOptions(params)...)
+}
+
+func (p *FacebookProvider) GetUserInformationByAuthorizationCode(code, redirectUri string, params AuthParams) (SocialProviderUser, error) {
 	config := p.NewOauth2Config(redirectUri)
-	token, err := config.Exchange(context.Background(), code)
+	token, err := config.Exchange(context.Background(), code, exchangeOptions(params)...)
 	if err != nil {
 		var retrieveError *oauth2.RetrieveError
 		if errors.As(err, &retrieveError) {
@@ -78,9 +82,12 @@ func (p *FacebookProvider) GetUserInformationByAuthorizationCode(code, redirectU
 		return SocialProviderUser{}, fmt.Errorf("failed to decode Facebook user info response: %v", err)
 	}
 
+	// The Graph API does not report whether the email was confirmed, so it is
+	// never trusted for matching existing users.
 	return SocialProviderUser{
 		ProviderUserID: user.ID,
 		Email:          user.Email,
+		EmailVerified:  false,
 		Name:           user.Name,
 		Avatar:         user.Picture.Data.URL,
 	}, nil
@@ -23,6 +23,7 @@ type GoogleClaims struct {
 	Name          string `json:"name"`
 	Picture       string `json:"picture"`
 	Sub           string `json:"sub"`
+	Nonce         string `json:"nonce"`
 	jwt.StandardClaims
 }
 
@@ -49,9 +50,14 @@ func (p *GoogleProvider) NewOauth2Config(redirectUri string) *oauth2.Config {
 	return conf
 }
 
-func (p *GoogleProvider) GetUserInformationByAuthorizationCode(code, redirectUri string) (SocialProviderUser, error) {
+func (p *GoogleProvider) AuthCodeURL(redirectUri string, params AuthParams) string {
+	opts := append(authCodeOptions(params), oauth2.SetAuthURLParam("nonce", params.Nonce))
+	return p.NewOauth2Config(redirectUri).AuthCodeURL(params.State, opts...)
+}
+
+func (p *GoogleProvider) GetUserInformationByAuthorizationCode(code, redirectUri string, params AuthParams) (SocialProviderUser, error) {
 	config := p.NewOauth2Config(redirectUri)
-	token, err := config.Exchange(context.Background(), code)
+	token, err := config.Exchange(context.Background(), code, exchangeOptions(params)...)
 	if err != nil {
 		var retrieveError *oauth2.RetrieveError
 		if errors.As(err, &retrieveError) {
@@ -71,9 +77,16 @@ func (p *GoogleProvider) GetUserInformationByAuthorizationCode(code, redirectUri
 		return SocialProviderUser{}, fmt.Errorf("failed to extract user claims from Google id_token: invalid claims format")
 	}
 
+	// Every authorization request carries a nonce, so an id_token without the
+	// expected one is rejected, as is a request that lost its nonce.
+	if params.Nonce == "" || claims.Nonce != params.Nonce {
+		return SocialProviderUser{}, ErrInvalidNonce
+	}
+
 	return SocialProviderUser{
 		ProviderUserID: claims.Sub,
 		Email:          claims.Email,
+		EmailVerified:  claims.EmailVerified,
 		Name:           claims.Name,
 		Avatar:         claims.Picture,
 	}, nil
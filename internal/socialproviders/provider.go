@@ -9,16 +9,26 @@ import (
 type SocialProvider interface {
 	ProviderName() string
 	NewOauth2Config(redirectUri string) *oauth2.Config
-	GetUserInformationByAuthorizationCode(code, redirectUri string) (SocialProviderUser, error)
+	AuthCodeURL(redirectUri string, params AuthParams) string
+	GetUserInformationByAuthorizationCode(code, redirectUri string, params AuthParams) (SocialProviderUser, error)
 }
 
 type SocialProviderUser struct {
 	ProviderUserID string
 	Email          string
+	EmailVerified  bool // Whether the provider confirmed the user owns Email
 	Name           string
 	Avatar         string
 }
 
+// AuthParams holds the per-login values that bind an authorization request
+// to the code exchange completing it.
+type AuthParams struct {
+	State        string
+	CodeVerifier string // PKCE verifier, sent as an S256 challenge and later as the verifier
+	Nonce        string // OpenID Connect nonce, ignored by providers without id_token support
+}
+
 func NewSocialProvider(provider string) (SocialProvider, error) {
 	switch provider {
 	case "google":
@@ -29,3 +39,18 @@ func NewSocialProvider(provider string) (SocialProvider, error) {
 		return nil, fmt.Errorf("%w: %s", ErrInvalidProvider, provider)
 	}
 }
+
+func authCodeOptions(params AuthParams) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if params.CodeVerifier != "" {
+		opts = append(opts, oauth2.S256ChallengeOption(params.CodeVerifier))
+	}
+	return opts
+}
+
+func exchangeOptions(params AuthParams) []oauth2.AuthCodeOption {
+	if params.CodeVerifier == "" {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{oauth2.VerifierOption(params.CodeVerifier)}
+}
@@ -5,32 +5,130 @@ package internal
 
 import (
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/handlers"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/hashers"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/mailers"
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/repositories"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/sessionstores"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/storage"
 	"github.com/Joe5451/go-oauth2-server/internal/application"
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
 	"github.com/Joe5451/go-oauth2-server/internal/database"
 	"github.com/Joe5451/go-oauth2-server/internal/http"
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
 
 var providerSet wire.ProviderSet = wire.NewSet(
 	database.NewPostgresDB,
+	sessionstores.NewSessionStore,
 
 	wire.Bind(new(out.UserRepository), new(*repositories.PostgresUserRepository)),
 	repositories.NewPostgresUserRepository,
 
+	wire.Bind(new(out.EmailVerificationRepository), new(*repositories.PostgresEmailVerificationRepository)),
+	repositories.NewPostgresEmailVerificationRepository,
+
+	wire.Bind(new(out.PasswordResetRepository), new(*repositories.PostgresPasswordResetRepository)),
+	repositories.NewPostgresPasswordResetRepository,
+
+	wire.Bind(new(out.MFARepository), new(*repositories.PostgresMFARepository)),
+	repositories.NewPostgresMFARepository,
+
+	wire.Bind(new(out.WebAuthnCredentialRepository), new(*repositories.PostgresWebAuthnCredentialRepository)),
+	repositories.NewPostgresWebAuthnCredentialRepository,
+
+	wire.Bind(new(out.MagicLinkRepository), new(*repositories.PostgresMagicLinkRepository)),
+	repositories.NewPostgresMagicLinkRepository,
+
+	wire.Bind(new(out.UserSessionRepository), new(*repositories.PostgresUserSessionRepository)),
+	repositories.NewPostgresUserSessionRepository,
+
+	wire.Bind(new(out.SocialLinkTokenRepository), new(*repositories.PostgresSocialLinkTokenRepository)),
+	repositories.NewPostgresSocialLinkTokenRepository,
+
+	repositories.NewLoginAttemptRepository,
+
+	wire.Bind(new(out.AccountUnlockTokenRepository), new(*repositories.PostgresAccountUnlockTokenRepository)),
+	repositories.NewPostgresAccountUnlockTokenRepository,
+
+	wire.Bind(new(out.BreachedPasswordRepository), new(*repositories.FileBreachedPasswordRepository)),
+	repositories.NewFileBreachedPasswordRepository,
+
+	wire.Bind(new(out.PasswordHasher), new(*hashers.PasswordHasher)),
+	hashers.NewPasswordHasher,
+
+	wire.Bind(new(out.Mailer), new(*mailers.TemplateMailer)),
+	mailers.NewMailer,
+
+	wire.Bind(new(out.DataExportRepository), new(*repositories.PostgresDataExportRepository)),
+	repositories.NewPostgresDataExportRepository,
+
+	wire.Bind(new(out.AvatarStorage), new(*storage.LocalAvatarStorage)),
+	storage.NewLocalAvatarStorage,
+
+	wire.Bind(new(out.ExportStorage), new(*storage.LocalExportStorage)),
+	storage.NewLocalExportStorage,
+
+	application.NewLoginProtectionService,
+	application.NewPasswordPolicy,
+	wire.Bind(new(in.AccountUnlockUsecase), new(*application.LoginProtectionService)),
+
+	wire.Bind(new(in.SessionUsecase), new(*application.SessionService)),
+	application.NewSessionService,
+
 	wire.Bind(new(in.UserUsecase), new(*application.UserService)),
 	application.NewUserService,
 
+	wire.Bind(new(in.EmailVerificationUsecase), new(*application.EmailVerificationService)),
+	application.NewEmailVerificationService,
+
+	wire.Bind(new(in.PasswordResetUsecase), new(*application.PasswordResetService)),
+	application.NewPasswordResetService,
+
+	wire.Bind(new(in.MFAUsecase), new(*application.MFAService)),
+	application.NewMFAService,
+
+	wire.Bind(new(in.PasskeyUsecase), new(*application.PasskeyService)),
+	application.NewPasskeyService,
+
+	wire.Bind(new(in.MagicLinkUsecase), new(*application.MagicLinkService)),
+	application.NewMagicLinkService,
+
+	wire.Bind(new(in.AccountPurgeUsecase), new(*application.AccountPurgeService)),
+	application.NewAccountPurgeService,
+
+	wire.Bind(new(in.DataExportUsecase), new(*application.DataExportService)),
+	application.NewDataExportService,
+
+	handlers.NewStateManager,
+	handlers.NewSessionManager,
+	handlers.NewRedirectURIPolicy,
 	handlers.NewUserHandler,
+	handlers.NewEmailHandler,
+	handlers.NewPasswordHandler,
+	handlers.NewMFAHandler,
+	handlers.NewPasskeyHandler,
+	handlers.NewMagicLinkHandler,
+	handlers.NewAccountHandler,
+	handlers.NewSessionHandler,
+	handlers.NewDataExportHandler,
+	handlers.NewSocialRedirectHandler,
 	handlers.NewTemplateHandler,
 
 	http.NewRouter,
+
+	NewApp,
 )
 
-func InitializeApp() (*gin.Engine, error) {
+func InitializeApp() (*App, func(), error) {
+	panic(
+		wire.Build(
+			providerSet,
+			mailers.NewTransport,
+		),
+	)
+}
+func InitializeAppWithMailTransport(transport mailers.Transport) (*App, func(), error) {
 	panic(
 		wire.Build(
 			providerSet,
@@ -8,31 +8,211 @@ package internal
 
 import (
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/handlers"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/hashers"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/mailers"
 	"github.com/Joe5451/go-oauth2-server/internal/adapter/repositories"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/sessionstores"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/storage"
 	"github.com/Joe5451/go-oauth2-server/internal/application"
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/application/ports/out"
 	"github.com/Joe5451/go-oauth2-server/internal/database"
 	"github.com/Joe5451/go-oauth2-server/internal/http"
-	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
 
 // Injectors from wire.go:
 
-func InitializeApp() (*gin.Engine, error) {
-	conn, err := database.NewPostgresDB()
+func InitializeApp() (*App, func(), error) {
+	db, cleanup, err := database.NewPostgresDB()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	postgresUserRepository := repositories.NewPostgresUserRepository(conn)
-	userService := application.NewUserService(postgresUserRepository)
-	userHandler := handlers.NewUserHandler(userService)
+	postgresUserRepository := repositories.NewPostgresUserRepository(db)
+	postgresSocialLinkTokenRepository := repositories.NewPostgresSocialLinkTokenRepository(db)
+	postgresEmailVerificationRepository := repositories.NewPostgresEmailVerificationRepository(db)
+	postgresPasswordResetRepository := repositories.NewPostgresPasswordResetRepository(db)
+	postgresDataExportRepository := repositories.NewPostgresDataExportRepository(db)
+	postgresMFARepository := repositories.NewPostgresMFARepository(db)
+	postgresWebAuthnCredentialRepository := repositories.NewPostgresWebAuthnCredentialRepository(db)
+	postgresMagicLinkRepository := repositories.NewPostgresMagicLinkRepository(db)
+	postgresUserSessionRepository := repositories.NewPostgresUserSessionRepository(db)
+	loginAttemptRepository, cleanup2, err := repositories.NewLoginAttemptRepository(db)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	postgresAccountUnlockTokenRepository := repositories.NewPostgresAccountUnlockTokenRepository(db)
+	transport, err := mailers.NewTransport()
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	templateMailer, err := mailers.NewMailer(transport)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	loginProtectionService := application.NewLoginProtectionService(postgresUserRepository, loginAttemptRepository, postgresAccountUnlockTokenRepository, templateMailer)
+	emailVerificationService := application.NewEmailVerificationService(postgresUserRepository, postgresEmailVerificationRepository, templateMailer)
+	fileBreachedPasswordRepository := repositories.NewFileBreachedPasswordRepository()
+	passwordPolicy := application.NewPasswordPolicy(fileBreachedPasswordRepository)
+	passwordHasher, err := hashers.NewPasswordHasher()
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	userService := application.NewUserService(postgresUserRepository, postgresSocialLinkTokenRepository, emailVerificationService, templateMailer, loginProtectionService, passwordPolicy, passwordHasher)
+	passwordResetService := application.NewPasswordResetService(postgresUserRepository, postgresPasswordResetRepository, templateMailer, loginProtectionService, passwordPolicy, passwordHasher)
+	mfaService := application.NewMFAService(postgresUserRepository, postgresMFARepository, loginProtectionService)
+	passkeyService, err := application.NewPasskeyService(postgresUserRepository, postgresWebAuthnCredentialRepository)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	magicLinkService := application.NewMagicLinkService(postgresUserRepository, postgresMagicLinkRepository, templateMailer, loginProtectionService)
+	localExportStorage := storage.NewLocalExportStorage()
+	dataExportService := application.NewDataExportService(postgresUserRepository, postgresEmailVerificationRepository, postgresPasswordResetRepository, postgresUserSessionRepository, postgresMFARepository, postgresWebAuthnCredentialRepository, postgresMagicLinkRepository, postgresAccountUnlockTokenRepository, postgresDataExportRepository, localExportStorage)
+	sessionService := application.NewSessionService(postgresUserSessionRepository, postgresUserRepository)
+	stateManager := handlers.NewStateManager()
+	sessionManager := handlers.NewSessionManager(sessionService)
+	store, cleanup3, err := sessionstores.NewSessionStore(db)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	redirectURIPolicy, err := handlers.NewRedirectURIPolicy()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	userHandler := handlers.NewUserHandler(userService, sessionManager, stateManager, redirectURIPolicy)
+	emailHandler := handlers.NewEmailHandler(emailVerificationService)
+	passwordHandler := handlers.NewPasswordHandler(passwordResetService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, sessionManager)
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService, sessionManager)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService, sessionManager)
+	accountHandler := handlers.NewAccountHandler(loginProtectionService)
+	sessionHandler := handlers.NewSessionHandler(sessionService, sessionManager)
+	dataExportHandler := handlers.NewDataExportHandler(dataExportService)
+	socialRedirectHandler := handlers.NewSocialRedirectHandler(userService, sessionManager, stateManager, redirectURIPolicy)
 	templateHandler := handlers.NewTemplateHandler()
-	engine := http.NewRouter(userHandler, templateHandler)
-	return engine, nil
+	engine, err := http.NewRouter(userService, sessionManager, store, userHandler, emailHandler, passwordHandler, mfaHandler, passkeyHandler, magicLinkHandler, accountHandler, sessionHandler, dataExportHandler, socialRedirectHandler, templateHandler)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	localAvatarStorage := storage.NewLocalAvatarStorage()
+	accountPurgeService := application.NewAccountPurgeService(postgresUserRepository, localAvatarStorage, localExportStorage)
+	app := NewApp(engine, accountPurgeService)
+	return app, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
+}
+
+func InitializeAppWithMailTransport(transport mailers.Transport) (*App, func(), error) {
+	db, cleanup, err := database.NewPostgresDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	postgresUserRepository := repositories.NewPostgresUserRepository(db)
+	postgresSocialLinkTokenRepository := repositories.NewPostgresSocialLinkTokenRepository(db)
+	postgresEmailVerificationRepository := repositories.NewPostgresEmailVerificationRepository(db)
+	postgresPasswordResetRepository := repositories.NewPostgresPasswordResetRepository(db)
+	postgresDataExportRepository := repositories.NewPostgresDataExportRepository(db)
+	postgresMFARepository := repositories.NewPostgresMFARepository(db)
+	postgresWebAuthnCredentialRepository := repositories.NewPostgresWebAuthnCredentialRepository(db)
+	postgresMagicLinkRepository := repositories.NewPostgresMagicLinkRepository(db)
+	postgresUserSessionRepository := repositories.NewPostgresUserSessionRepository(db)
+	loginAttemptRepository, cleanup2, err := repositories.NewLoginAttemptRepository(db)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	postgresAccountUnlockTokenRepository := repositories.NewPostgresAccountUnlockTokenRepository(db)
+	templateMailer, err := mailers.NewMailer(transport)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	loginProtectionService := application.NewLoginProtectionService(postgresUserRepository, loginAttemptRepository, postgresAccountUnlockTokenRepository, templateMailer)
+	emailVerificationService := application.NewEmailVerificationService(postgresUserRepository, postgresEmailVerificationRepository, templateMailer)
+	fileBreachedPasswordRepository := repositories.NewFileBreachedPasswordRepository()
+	passwordPolicy := application.NewPasswordPolicy(fileBreachedPasswordRepository)
+	passwordHasher, err := hashers.NewPasswordHasher()
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	userService := application.NewUserService(postgresUserRepository, postgresSocialLinkTokenRepository, emailVerificationService, templateMailer, loginProtectionService, passwordPolicy, passwordHasher)
+	passwordResetService := application.NewPasswordResetService(postgresUserRepository, postgresPasswordResetRepository, templateMailer, loginProtectionService, passwordPolicy, passwordHasher)
+	mfaService := application.NewMFAService(postgresUserRepository, postgresMFARepository, loginProtectionService)
+	passkeyService, err := application.NewPasskeyService(postgresUserRepository, postgresWebAuthnCredentialRepository)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	magicLinkService := application.NewMagicLinkService(postgresUserRepository, postgresMagicLinkRepository, templateMailer, loginProtectionService)
+	localExportStorage := storage.NewLocalExportStorage()
+	dataExportService := application.NewDataExportService(postgresUserRepository, postgresEmailVerificationRepository, postgresPasswordResetRepository, postgresUserSessionRepository, postgresMFARepository, postgresWebAuthnCredentialRepository, postgresMagicLinkRepository, postgresAccountUnlockTokenRepository, postgresDataExportRepository, localExportStorage)
+	sessionService := application.NewSessionService(postgresUserSessionRepository, postgresUserRepository)
+	stateManager := handlers.NewStateManager()
+	sessionManager := handlers.NewSessionManager(sessionService)
+	store, cleanup3, err := sessionstores.NewSessionStore(db)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	redirectURIPolicy, err := handlers.NewRedirectURIPolicy()
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	userHandler := handlers.NewUserHandler(userService, sessionManager, stateManager, redirectURIPolicy)
+	emailHandler := handlers.NewEmailHandler(emailVerificationService)
+	passwordHandler := handlers.NewPasswordHandler(passwordResetService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, sessionManager)
+	passkeyHandler := handlers.NewPasskeyHandler(passkeyService, sessionManager)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService, sessionManager)
+	accountHandler := handlers.NewAccountHandler(loginProtectionService)
+	sessionHandler := handlers.NewSessionHandler(sessionService, sessionManager)
+	dataExportHandler := handlers.NewDataExportHandler(dataExportService)
+	socialRedirectHandler := handlers.NewSocialRedirectHandler(userService, sessionManager, stateManager, redirectURIPolicy)
+	templateHandler := handlers.NewTemplateHandler()
+	engine, err := http.NewRouter(userService, sessionManager, store, userHandler, emailHandler, passwordHandler, mfaHandler, passkeyHandler, magicLinkHandler, accountHandler, sessionHandler, dataExportHandler, socialRedirectHandler, templateHandler)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	localAvatarStorage := storage.NewLocalAvatarStorage()
+	accountPurgeService := application.NewAccountPurgeService(postgresUserRepository, localAvatarStorage, localExportStorage)
+	app := NewApp(engine, accountPurgeService)
+	return app, func() {
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
 }
 
 // wire.go:
 
-var providerSet wire.ProviderSet = wire.NewSet(database.NewPostgresDB, wire.Bind(new(out.UserRepository), new(*repositories.PostgresUserRepository)), repositories.NewPostgresUserRepository, wire.Bind(new(in.UserUsecase), new(*application.UserService)), application.NewUserService, handlers.NewUserHandler, handlers.NewTemplateHandler, http.NewRouter)
+var providerSet wire.ProviderSet = wire.NewSet(database.NewPostgresDB, sessionstores.NewSessionStore, wire.Bind(new(out.UserRepository), new(*repositories.PostgresUserRepository)), repositories.NewPostgresUserRepository, wire.Bind(new(out.EmailVerificationRepository), new(*repositories.PostgresEmailVerificationRepository)), repositories.NewPostgresEmailVerificationRepository, wire.Bind(new(out.PasswordResetRepository), new(*repositories.PostgresPasswordResetRepository)), repositories.NewPostgresPasswordResetRepository, wire.Bind(new(out.MFARepository), new(*repositories.PostgresMFARepository)), repositories.NewPostgresMFARepository, wire.Bind(new(out.WebAuthnCredentialRepository), new(*repositories.PostgresWebAuthnCredentialRepository)), repositories.NewPostgresWebAuthnCredentialRepository, wire.Bind(new(out.MagicLinkRepository), new(*repositories.PostgresMagicLinkRepository)), repositories.NewPostgresMagicLinkRepository, wire.Bind(new(out.UserSessionRepository), new(*repositories.PostgresUserSessionRepository)), repositories.NewPostgresUserSessionRepository, wire.Bind(new(out.SocialLinkTokenRepository), new(*repositories.PostgresSocialLinkTokenRepository)), repositories.NewPostgresSocialLinkTokenRepository, repositories.NewLoginAttemptRepository, wire.Bind(new(out.AccountUnlockTokenRepository), new(*repositories.PostgresAccountUnlockTokenRepository)), repositories.NewPostgresAccountUnlockTokenRepository, wire.Bind(new(out.BreachedPasswordRepository), new(*repositories.FileBreachedPasswordRepository)), repositories.NewFileBreachedPasswordRepository, wire.Bind(new(out.PasswordHasher), new(*hashers.PasswordHasher)), hashers.NewPasswordHasher, wire.Bind(new(out.Mailer), new(*mailers.TemplateMailer)), mailers.NewMailer, wire.Bind(new(out.DataExportRepository), new(*repositories.PostgresDataExportRepository)), repositories.NewPostgresDataExportRepository, wire.Bind(new(out.AvatarStorage), new(*storage.LocalAvatarStorage)), storage.NewLocalAvatarStorage, wire.Bind(new(out.ExportStorage), new(*storage.LocalExportStorage)), storage.NewLocalExportStorage, application.NewLoginProtectionService, application.NewPasswordPolicy, wire.Bind(new(in.AccountUnlockUsecase), new(*application.LoginProtectionService)), wire.Bind(new(in.SessionUsecase), new(*application.SessionService)), application.NewSessionService, wire.Bind(new(in.UserUsecase), new(*application.UserService)), application.NewUserService, wire.Bind(new(in.EmailVerificationUsecase), new(*application.EmailVerificationService)), application.NewEmailVerificationService, wire.Bind(new(in.PasswordResetUsecase), new(*application.PasswordResetService)), application.NewPasswordResetService, wire.Bind(new(in.MFAUsecase), new(*application.MFAService)), application.NewMFAService, wire.Bind(new(in.PasskeyUsecase), new(*application.PasskeyService)), application.NewPasskeyService, wire.Bind(new(in.MagicLinkUsecase), new(*application.MagicLinkService)), application.NewMagicLinkService, wire.Bind(new(in.AccountPurgeUsecase), new(*application.AccountPurgeService)), application.NewAccountPurgeService, wire.Bind(new(in.DataExportUsecase), new(*application.DataExportService)), application.NewDataExportService, handlers.NewStateManager, handlers.NewSessionManager, handlers.NewRedirectURIPolicy, handlers.NewUserHandler, handlers.NewEmailHandler, handlers.NewPasswordHandler, handlers.NewMFAHandler, handlers.NewPasskeyHandler, handlers.NewMagicLinkHandler, handlers.NewAccountHandler, handlers.NewSessionHandler, handlers.NewDataExportHandler, handlers.NewSocialRedirectHandler, handlers.NewTemplateHandler, http.NewRouter, NewApp)
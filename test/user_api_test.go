@@ -1,22 +1,37 @@
 package test
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Joe5451/go-oauth2-server/internal"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/mailers"
+	"github.com/Joe5451/go-oauth2-server/internal/adapter/repositories"
+	"github.com/Joe5451/go-oauth2-server/internal/application/ports/in"
 	"github.com/Joe5451/go-oauth2-server/internal/config"
 	"github.com/Joe5451/go-oauth2-server/internal/database"
+	"github.com/Joe5451/go-oauth2-server/internal/domain"
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5"
+	"github.com/golang-jwt/jwt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pquerna/otp/totp"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/crypto/bcrypt"
@@ -25,9 +40,12 @@ import (
 type TestSuite struct {
 	suite.Suite
 	router    *gin.Engine
+	cleanup   func()
 	csrfToken string
 	cookies   []*http.Cookie
-	conn      *pgx.Conn
+	db        *pgxpool.Pool
+	outbox    *mailers.MemoryTransport
+	purger    in.AccountPurgeUsecase
 }
 
 func (s *TestSuite) SetupSuite() {
@@ -39,16 +57,28 @@ func (s *TestSuite) SetupSuite() {
 
 	s.Require().NoError(viper.ReadInConfig(), "Error reading .env.test file")
 	s.Require().NoError(viper.Unmarshal(&config.AppConfig), "Error unmarshalling config")
+	config.AppConfig.MailDriver = "memory"
+	config.AppConfig.SessionStore = "memory"
+	config.AppConfig.LoginAttemptStore = "memory"
 
 	var err error
-	s.router, err = internal.InitializeApp()
-	s.Require().NoError(err)
-
-	s.conn, err = database.NewPostgresDB()
+	s.db, _, err = database.NewPostgresDB()
 	s.Require().NoError(err, "Failed to connect database for cleanup")
 }
 
+func (s *TestSuite) TearDownSuite() {
+	s.db.Close()
+}
+
 func (s *TestSuite) SetupTest() {
+	// Every test gets a new app, so that the in-memory sessions and login
+	// attempts of one test do not leak into the next.
+	s.outbox = mailers.NewMemoryTransport()
+
+	app, cleanup, err := internal.InitializeAppWithMailTransport(s.outbox)
+	s.Require().NoError(err)
+	s.router, s.purger, s.cleanup = app.Router, app.Purger, cleanup
+
 	req, _ := http.NewRequest("GET", "/api/csrf-token", nil)
 	w := httptest.NewRecorder()
 	s.router.ServeHTTP(w, req)
@@ -60,7 +90,7 @@ func (s *TestSuite) SetupTest() {
 }
 
 func (s *TestSuite) TearDownTest() {
-	tx, err := s.conn.Begin(context.Background())
+	tx, err := s.db.Begin(context.Background())
 	s.Require().NoError(err, "Failed to start transaction for cleanup")
 
 	_, err = tx.Exec(context.Background(), `
@@ -76,13 +106,17 @@ func (s *TestSuite) TearDownTest() {
 
 	err = tx.Commit(context.Background())
 	s.Require().NoError(err, "Failed to commit cleanup transaction")
+
+	if s.cleanup != nil {
+		s.cleanup()
+	}
 }
 
 func (s *TestSuite) createTestUser(name, email, password string) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
 	s.Require().NoError(err, "Failed to hash password")
 
-	_, err = s.conn.Exec(context.Background(), `
+	_, err = s.db.Exec(context.Background(), `
 		INSERT INTO users (email, password, name) VALUES ($1, $2, $3)
 	`, email, string(hashedPassword), name)
 	s.Require().NoError(err, "Failed to insert test user")
@@ -102,13 +136,77 @@ func (s *TestSuite) loginTestUser(email, password string) {
 	s.router.ServeHTTP(w, req)
 
 	s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+	s.saveCookies(w)
+}
+
+// saveCookies keeps the cookies set by the response like a browser would,
+// replacing those of the same name. The session cookie changes whenever the
+// session ID is rotated on sign-in.
+func (s *TestSuite) saveCookies(w *httptest.ResponseRecorder) {
 	for _, cookie := range w.Result().Cookies() {
-		if cookie.Name == "usersession" {
+		s.cookies = slices.DeleteFunc(s.cookies, func(saved *http.Cookie) bool {
+			return saved.Name == cookie.Name
+		})
+		if cookie.MaxAge >= 0 {
 			s.cookies = append(s.cookies, cookie)
 		}
 	}
 }
 
+func (s *TestSuite) getTestUser() map[string]any {
+	req, _ := http.NewRequest("GET", "/api/user", nil)
+	for _, cookie := range s.cookies {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+	var user map[string]any
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&user))
+	return user
+}
+
+// socialLogin signs in with the Google account providerUserID through the API,
+// keeping the cookies like a browser would.
+func (s *TestSuite) socialLogin(providerUserID, email string, emailVerified bool) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/login/social/google?redirect_uri=http://localhost/callback", nil)
+	for _, cookie := range s.cookies {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+	s.saveCookies(w)
+
+	var body map[string]string
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+	authUrl, err := url.Parse(body["auth_url"])
+	s.Require().NoError(err)
+
+	restore := fakeGoogleTokenExchange(providerUserID, email, emailVerified)
+	defer restore()
+
+	payload := fmt.Sprintf(
+		`{"provider": "google", "code": "%s", "state": "%s", "redirect_uri": "http://localhost/callback"}`,
+		authUrl.Query().Get("nonce"), authUrl.Query().Get("state"),
+	)
+	req, _ = http.NewRequest("POST", "/api/login/social/callback", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+	for _, cookie := range s.cookies {
+		req.AddCookie(cookie)
+	}
+
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.saveCookies(w)
+	return w
+}
+
 func (s *TestSuite) TestCSRFToken() {
 	req, _ := http.NewRequest("GET", "/api/csrf-token", nil)
 	w := httptest.NewRecorder()
@@ -135,6 +233,100 @@ func (s *TestSuite) TestRegister() {
 
 		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
 	})
+
+	s.Run("should reject a password that breaks the password policy", func() {
+		payload := `{"email": "weak-password@example.com", "password": "weak", "name": "Weak Password"}`
+		req, _ := http.NewRequest("POST", "/api/register", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+		var body struct {
+			Code   string `json:"code"`
+			Errors []struct {
+				Field string `json:"field"`
+				Code  string `json:"code"`
+			} `json:"errors"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("VALIDATION_ERROR", body.Code)
+
+		codes := []string{}
+		for _, fieldError := range body.Errors {
+			s.Equal("password", fieldError.Field)
+			codes = append(codes, fieldError.Code)
+		}
+		s.ElementsMatch([]string{"PASSWORD_TOO_SHORT", "PASSWORD_TOO_SIMPLE", "PASSWORD_SIMILAR_TO_ACCOUNT"}, codes)
+	})
+
+	s.Run("should send a verification email that verifies the address", func() {
+		payload := `{"email": "verify-me@example.com", "password": "f205c9241173", "name": "Verify Me"}`
+		req, _ := http.NewRequest("POST", "/api/register", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code)
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal("verify-me@example.com", messages[0].To)
+
+		matches := regexp.MustCompile(`/template/email/verify\?token=(\S+)`).FindStringSubmatch(messages[0].TextBody)
+		s.Require().Len(matches, 2, "Expected a verification link in the mail body")
+		token, err := url.QueryUnescape(matches[1])
+		s.Require().NoError(err)
+
+		req, _ = http.NewRequest("POST", "/api/email/verify", strings.NewReader(fmt.Sprintf(`{"token": "%s"}`, token)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		var verifiedAt *string
+		err = s.db.QueryRow(context.Background(), `SELECT email_verified_at::text FROM users WHERE email = $1`, "verify-me@example.com").Scan(&verifiedAt)
+		s.Require().NoError(err)
+		s.NotNil(verifiedAt)
+	})
+}
+
+func (s *TestSuite) TestBreachedPasswordImport() {
+	breachedPasswordDir := config.AppConfig.BreachedPasswordDir
+	defer func() { config.AppConfig.BreachedPasswordDir = breachedPasswordDir }()
+
+	s.Run("should leave the corpus unchanged when the same hashes are imported again", func() {
+		config.AppConfig.BreachedPasswordDir = s.T().TempDir()
+		repo := repositories.NewFileBreachedPasswordRepository()
+
+		hashes := "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n5BAA6000000000000000000000000000000000AA\n"
+		for range 2 {
+			imported, err := repo.Import(strings.NewReader(hashes))
+			s.Require().NoError(err)
+			s.Equal(2, imported)
+		}
+
+		data, err := os.ReadFile(filepath.Join(config.AppConfig.BreachedPasswordDir, "5BAA6.txt"))
+		s.Require().NoError(err)
+		s.Equal("000000000000000000000000000000000AA:1\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3\n", string(data))
+	})
 }
 
 func (s *TestSuite) TestLogin() {
@@ -157,6 +349,47 @@ func (s *TestSuite) TestLogin() {
 
 		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
 	})
+
+	s.Run("should upgrade an outdated password hash on login", func() {
+		email := "outdated-hash@example.com"
+		password := "f205c9241173"
+		s.createTestUser("Outdated Hash", email, password)
+
+		s.loginTestUser(email, password)
+
+		var hash string
+		err := s.db.QueryRow(context.Background(), `SELECT password FROM users WHERE email = $1`, email).Scan(&hash)
+		s.Require().NoError(err)
+		s.True(strings.HasPrefix(hash, "$argon2id$"), "Expected the bcrypt hash to be replaced with an argon2id hash")
+
+		s.loginTestUser(email, password)
+	})
+
+	s.Run("should reject a password hash in an unrecognized format as invalid credentials", func() {
+		email := "unknown-hash@example.com"
+		password := "f205c9241173"
+		s.createTestUser("Unknown Hash", email, password)
+
+		_, err := s.db.Exec(context.Background(), `UPDATE users SET password = '$md5$not-a-supported-hash' WHERE email = $1`, email)
+		s.Require().NoError(err)
+
+		loginPayload := fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)
+		req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_CREDENTIALS", body["code"])
+	})
 }
 
 func (s *TestSuite) TestLogout() {
@@ -214,8 +447,14 @@ func (s *TestSuite) TestGetUser() {
 		s.router.ServeHTTP(w, req)
 		s.Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
 
-		expectedBody := fmt.Sprintf(`{"email":"%s","name":"%s","avatar":null,"social_accounts":[]}`, email, name)
-		s.Equal(expectedBody, w.Body.String(), "Expected body to match")
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal(email, body["email"])
+		s.Equal(name, body["name"])
+		s.Nil(body["avatar"])
+		s.Nil(body["pending_email"])
+		s.Equal([]any{}, body["social_accounts"])
+		s.NotEmpty(body["updated_at"])
 	})
 }
 
@@ -234,7 +473,7 @@ func (s *TestSuite) TestSocialAuthURL() {
 		authURL := body["auth_url"]
 
 		expectedRegex := fmt.Sprintf(
-			`^https://accounts\.google\.com/o/oauth2/auth\?access_type=offline&client_id=%s&redirect_uri=%s&response_type=code&scope=openid\+profile\+email&state=[a-f0-9]{64}$`,
+			`^https://accounts\.google\.com/o/oauth2/auth\?access_type=offline&client_id=%s&code_challenge=[A-Za-z0-9_-]{43}&code_challenge_method=S256&nonce=[a-f0-9]{64}&redirect_uri=%s&response_type=code&scope=openid\+profile\+email&state=[a-f0-9]{64}$`,
 			regexp.QuoteMeta(config.AppConfig.GoogleOauth2ClientID),
 			regexp.QuoteMeta(url.QueryEscape("http://localhost/callback")),
 		)
@@ -257,7 +496,7 @@ func (s *TestSuite) TestSocialAuthURL() {
 		authURL := body["auth_url"]
 
 		expectedRegex := fmt.Sprintf(
-			`^https://www\.facebook\.com/v3\.2/dialog/oauth\?access_type=offline&client_id=%s&redirect_uri=%s&response_type=code&scope=email&state=[a-f0-9]{64}$`,
+			`^https://www\.facebook\.com/v3\.2/dialog/oauth\?access_type=offline&client_id=%s&code_challenge=[A-Za-z0-9_-]{43}&code_challenge_method=S256&redirect_uri=%s&response_type=code&scope=email&state=[a-f0-9]{64}$`,
 			regexp.QuoteMeta(config.AppConfig.FacebookOauth2ClientID),
 			regexp.QuoteMeta(url.QueryEscape("http://localhost/callback")),
 		)
@@ -268,6 +507,1926 @@ func (s *TestSuite) TestSocialAuthURL() {
 	})
 }
 
+func (s *TestSuite) TestSocialAuthURLRedirectNotAllowed() {
+	s.Run("should reject a redirect_uri outside the allow-list", func() {
+		req, _ := http.NewRequest("GET", "/api/login/social/google?redirect_uri=https://attacker.example/callback", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code)
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("REDIRECT_URI_NOT_ALLOWED", body["code"])
+	})
+}
+
+func (s *TestSuite) TestSocialAuthCallbackInvalidState() {
+	s.Run("should reject a state that was not issued for this session", func() {
+		payload := `{"provider": "google", "code": "code", "state": "unknown-state", "redirect_uri": "http://localhost/callback"}`
+		req, _ := http.NewRequest("POST", "/api/login/social/callback", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_STATE", body["code"])
+	})
+}
+
+func (s *TestSuite) TestSocialLogin() {
+	email := "social@example.com"
+	s.createTestUser("Test User", email, "password")
+	_, err := s.db.Exec(context.Background(), `
+		UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE email = $1
+	`, email)
+	s.Require().NoError(err)
+
+	// linkedUserID returns the user the Google account is linked to, if any.
+	linkedUserID := func(providerUserID string) *int64 {
+		var userID *int64
+		err := s.db.QueryRow(context.Background(), `
+			SELECT user_id FROM social_accounts WHERE provider = 'google' AND provider_user_id = $1
+		`, providerUserID).Scan(&userID)
+		s.Require().NoError(err)
+		return userID
+	}
+
+	s.Run("should not link an unverified provider email to the existing user", func() {
+		w := s.socialLogin("unverified-google-id", email, false)
+
+		s.Equal(http.StatusConflict, w.Code, "Expected status code 409 Conflict")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("UNVERIFIED_SOCIAL_EMAIL", body["code"])
+
+		s.Nil(linkedUserID("unverified-google-id"), "Expected the social account to stay unlinked")
+	})
+
+	s.Run("should ask the existing user to confirm the link of a verified provider email", func() {
+		w := s.socialLogin("verified-google-id", email, true)
+
+		s.Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("link_required", body["code"])
+		s.NotEmpty(body["link_token"])
+
+		s.Nil(linkedUserID("verified-google-id"), "Expected the social account to stay unlinked until the link is confirmed")
+	})
+
+	s.Run("should sign up a new user with a verified provider email", func() {
+		w := s.socialLogin("new-google-id", "new-social@example.com", true)
+
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		user := s.getTestUser()
+		s.Equal("new-social@example.com", user["email"])
+		s.NotNil(linkedUserID("new-google-id"), "Expected the social account to be linked to the new user")
+	})
+}
+
+func (s *TestSuite) TestSocialLoginWithoutEmail() {
+	// linkedUserID returns the user the Google account is linked to.
+	linkedUserID := func(providerUserID string) int64 {
+		var userID int64
+		err := s.db.QueryRow(context.Background(), `
+			SELECT user_id FROM social_accounts WHERE provider = 'google' AND provider_user_id = $1
+		`, providerUserID).Scan(&userID)
+		s.Require().NoError(err)
+		return userID
+	}
+
+	s.Run("should sign up each provider user without an email as their own user", func() {
+		w := s.socialLogin("first-emailless-google-id", "", false)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.Nil(s.getTestUser()["email"])
+
+		// A user without an email must not be matched by the next sign-up
+		// without one, nor trip the unique email constraint.
+		w = s.socialLogin("second-emailless-google-id", "", false)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.Nil(s.getTestUser()["email"])
+
+		s.NotEqual(
+			linkedUserID("first-emailless-google-id"),
+			linkedUserID("second-emailless-google-id"),
+			"Expected each provider user to get their own user",
+		)
+	})
+
+	s.Run("should sign in the same user again without an email", func() {
+		w := s.socialLogin("returning-emailless-google-id", "", false)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		userID := linkedUserID("returning-emailless-google-id")
+
+		w = s.socialLogin("returning-emailless-google-id", "", false)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.Equal(userID, linkedUserID("returning-emailless-google-id"))
+
+		var users int
+		err := s.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM users WHERE email IS NULL`).Scan(&users)
+		s.Require().NoError(err)
+		s.Equal(3, users, "Expected no user to be created for the returning provider user")
+	})
+}
+
+func (s *TestSuite) TestSocialRedirectLogin() {
+	s.Run("should redirect to the provider with the server callback URL", func() {
+		req, _ := http.NewRequest("GET", "/auth/social/google", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		s.Require().NoError(err)
+		s.Equal("accounts.google.com", location.Host)
+		s.Equal(config.AppConfig.AppBaseUrl+"/auth/social/google/callback", location.Query().Get("redirect_uri"))
+		s.Regexp(`^[a-f0-9]{64}$`, location.Query().Get("state"))
+	})
+
+	s.Run("should redirect a callback without a matching state to the client with the error", func() {
+		req, _ := http.NewRequest("GET", "/auth/social/google/callback?code=code&state=unknown-state", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		s.Require().NoError(err)
+		s.Equal("/template/login", location.Path)
+		s.Equal("INVALID_STATE", location.Query().Get("error"))
+	})
+
+	s.Run("should redirect a provider error to the client", func() {
+		req, _ := http.NewRequest("GET", "/auth/social/google/callback?error=access_denied", nil)
+		w := httptest.NewRecorder()
+
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusFound, w.Code)
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		s.Require().NoError(err)
+		s.Equal("VALIDATION_ERROR", location.Query().Get("error"))
+	})
+}
+
+func (s *TestSuite) TestVerifyEmail() {
+	s.Run("should reject an invalid verification token", func() {
+		req, _ := http.NewRequest("POST", "/api/email/verify", strings.NewReader(`{"token": "invalid-token"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_VERIFICATION_TOKEN", body["code"])
+	})
+}
+
+func (s *TestSuite) TestResendVerificationEmail() {
+	s.Run("should not reveal whether the email is registered", func() {
+		req, _ := http.NewRequest("POST", "/api/email/verify/resend", strings.NewReader(`{"email": "nobody@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+	})
+
+	s.Run("should send the link to an email the user is changing to", func() {
+		email := "changing@example.com"
+		s.createTestUser("Changing", email, "f205c9241173")
+		_, err := s.db.Exec(context.Background(), `
+			UPDATE users SET email_verified_at = CURRENT_TIMESTAMP, pending_email = 'changed@example.com' WHERE email = $1
+		`, email)
+		s.Require().NoError(err)
+
+		req, _ := http.NewRequest("POST", "/api/email/verify/resend", strings.NewReader(`{"email": "changed@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal("changed@example.com", messages[0].To)
+	})
+}
+
+func (s *TestSuite) TestPasswordReset() {
+	s.Run("should not reveal whether the email is registered", func() {
+		req, _ := http.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email": "nobody@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.Empty(s.outbox.Messages())
+	})
+
+	s.Run("should reset the password once and sign out existing sessions", func() {
+		email := "forgetful@example.com"
+		s.createTestUser("Forgetful", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("POST", "/api/password/forgot", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code)
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal(email, messages[0].To)
+
+		matches := regexp.MustCompile(`/template/password/reset\?token=(\S+)`).FindStringSubmatch(messages[0].TextBody)
+		s.Require().Len(matches, 2, "Expected a reset link in the mail body")
+		token, err := url.QueryUnescape(matches[1])
+		s.Require().NoError(err)
+
+		resetPayload := fmt.Sprintf(`{"token": "%s", "password": "new-password-123"}`, token)
+
+		req, _ = http.NewRequest("POST", "/api/password/reset", strings.NewReader(resetPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		req, _ = http.NewRequest("GET", "/api/user", nil)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected the existing session to be signed out")
+
+		req, _ = http.NewRequest("POST", "/api/password/reset", strings.NewReader(resetPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusBadRequest, w.Code, "Expected the token to be single use")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_PASSWORD_RESET_TOKEN", body["code"])
+
+		s.loginTestUser(email, "new-password-123")
+	})
+}
+
+func (s *TestSuite) TestChangePassword() {
+	s.Run("should reject an incorrect current password", func() {
+		email := "changer@example.com"
+		s.createTestUser("Changer", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("PUT", "/api/user/password", strings.NewReader(`{"current_password": "wrong", "password": "new-password-123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INCORRECT_PASSWORD", body["code"])
+	})
+
+	s.Run("should change the password and notify the user", func() {
+		email := "changer-2@example.com"
+		s.createTestUser("Changer", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("PUT", "/api/user/password", strings.NewReader(`{"current_password": "f205c9241173", "password": "new-password-123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal(email, messages[0].To)
+		s.Equal("Your password was changed", messages[0].Subject)
+
+		s.loginTestUser(email, "new-password-123")
+	})
+
+	setPassword := func(email string) *httptest.ResponseRecorder {
+		_, err := s.db.Exec(context.Background(), `UPDATE users SET password = NULL WHERE email = $1`, email)
+		s.Require().NoError(err, "Failed to make the user social-only")
+
+		req, _ := http.NewRequest("PUT", "/api/user/password", strings.NewReader(`{"password": "new-password-123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	s.Run("should require a recent sign-in before a social-only user sets a password", func() {
+		reauthWindow := config.AppConfig.ReauthWindow
+		config.AppConfig.ReauthWindow = 50 * time.Millisecond
+		defer func() { config.AppConfig.ReauthWindow = reauthWindow }()
+
+		email := "social-only-stale@example.com"
+		s.createTestUser("Social Only", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		time.Sleep(100 * time.Millisecond)
+
+		w := setPassword(email)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("REAUTH_REQUIRED", body["code"])
+	})
+
+	s.Run("should let a social-only user who just signed in set a password and stay signed in", func() {
+		email := "social-only-fresh@example.com"
+		s.createTestUser("Social Only", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		w := setPassword(email)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		s.Equal(email, s.getTestUser()["email"], "Expected the session restarted after the revocation to stay signed in")
+
+		s.loginTestUser(email, "new-password-123")
+	})
+}
+
+func (s *TestSuite) TestUpdateUser() {
+	s.Run("should update the name and reject stale updates", func() {
+		email := "editor@example.com"
+		s.createTestUser("Editor", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		updatedAt := s.getTestUser()["updated_at"]
+		payload := fmt.Sprintf(`{"name": "Renamed Editor", "updated_at": "%s"}`, updatedAt)
+
+		req, _ := http.NewRequest("PATCH", "/api/user", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+		s.Equal("Renamed Editor", s.getTestUser()["name"])
+
+		req, _ = http.NewRequest("PATCH", "/api/user", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusConflict, w.Code, "Expected status code 409 Conflict")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("USER_MODIFIED", body["code"])
+	})
+
+	s.Run("should only change the email once the new address is verified", func() {
+		email := "mover@example.com"
+		s.createTestUser("Mover", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		updatedAt := s.getTestUser()["updated_at"]
+		payload := fmt.Sprintf(`{"email": "moved@example.com", "updated_at": "%s"}`, updatedAt)
+
+		req, _ := http.NewRequest("PATCH", "/api/user", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		user := s.getTestUser()
+		s.Equal(email, user["email"])
+		s.Equal("moved@example.com", user["pending_email"])
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal("moved@example.com", messages[0].To)
+
+		matches := regexp.MustCompile(`/template/email/verify\?token=(\S+)`).FindStringSubmatch(messages[0].TextBody)
+		s.Require().Len(matches, 2, "Expected a verification link in the mail body")
+		token, err := url.QueryUnescape(matches[1])
+		s.Require().NoError(err)
+
+		req, _ = http.NewRequest("POST", "/api/email/verify", strings.NewReader(fmt.Sprintf(`{"token": "%s"}`, token)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		user = s.getTestUser()
+		s.Equal("moved@example.com", user["email"])
+		s.Nil(user["pending_email"])
+	})
+}
+
+func (s *TestSuite) TestDeleteUser() {
+	s.Run("should soft delete the user and restore it on the next login", func() {
+		email := "leaver@example.com"
+		s.createTestUser("Leaver", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("DELETE", "/api/user", strings.NewReader(`{"password": "f205c9241173"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.NotEmpty(body["purge_at"])
+
+		var deletedAt *string
+		err := s.db.QueryRow(context.Background(), `SELECT deleted_at::text FROM users WHERE email = $1`, email).Scan(&deletedAt)
+		s.Require().NoError(err)
+		s.NotNil(deletedAt)
+
+		s.loginTestUser(email, "f205c9241173")
+
+		err = s.db.QueryRow(context.Background(), `SELECT deleted_at::text FROM users WHERE email = $1`, email).Scan(&deletedAt)
+		s.Require().NoError(err)
+		s.Nil(deletedAt)
+	})
+
+	s.Run("should restore the user only once the second factor passes", func() {
+		email := "leaver-mfa@example.com"
+		s.createTestUser("Leaver MFA", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		send := func(method, path, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			s.saveCookies(w)
+			return w
+		}
+
+		w := send("POST", "/api/user/mfa/totp", "")
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var enrollment struct {
+			Secret string `json:"secret"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&enrollment))
+
+		code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+		s.Require().NoError(err)
+
+		w = send("POST", "/api/user/mfa/totp/confirm", fmt.Sprintf(`{"code": "%s"}`, code))
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var confirmation struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&confirmation))
+
+		w = send("DELETE", "/api/user", `{"password": "f205c9241173"}`)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		w = send("POST", "/api/login", fmt.Sprintf(`{"email": "%s", "password": "f205c9241173"}`, email))
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var deletedAt *string
+		err = s.db.QueryRow(context.Background(), `SELECT deleted_at::text FROM users WHERE email = $1`, email).Scan(&deletedAt)
+		s.Require().NoError(err)
+		s.NotNil(deletedAt, "Expected the password alone not to restore the user")
+
+		w = send("POST", "/api/login/mfa", fmt.Sprintf(`{"code": "%s"}`, confirmation.RecoveryCodes[0]))
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		err = s.db.QueryRow(context.Background(), `SELECT deleted_at::text FROM users WHERE email = $1`, email).Scan(&deletedAt)
+		s.Require().NoError(err)
+		s.Nil(deletedAt)
+	})
+
+	s.Run("should purge users past the grace period with their social accounts", func() {
+		email := "purged@example.com"
+		s.createTestUser("Purged", email, "f205c9241173")
+
+		_, err := s.db.Exec(context.Background(), `
+			INSERT INTO social_accounts (user_id, provider, provider_user_id)
+			SELECT id, 'google', 'purged-google-id' FROM users WHERE email = $1
+		`, email)
+		s.Require().NoError(err)
+
+		_, err = s.db.Exec(context.Background(), `UPDATE users SET deleted_at = NOW() - INTERVAL '365 days' WHERE email = $1`, email)
+		s.Require().NoError(err)
+
+		purged, err := s.purger.PurgeDeletedUsers()
+		s.Require().NoError(err)
+		s.Equal(1, purged)
+
+		var count int
+		err = s.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM social_accounts WHERE provider_user_id = 'purged-google-id'`).Scan(&count)
+		s.Require().NoError(err)
+		s.Zero(count)
+	})
+
+	s.Run("should not purge a user deleted again after the cutoff", func() {
+		email := "redeleted@example.com"
+		s.createTestUser("Redeleted", email, "f205c9241173")
+
+		// The user was restored and deleted again after being listed for the
+		// purge, so the purge must re-check the deletion time.
+		var userID int64
+		err := s.db.QueryRow(context.Background(), `
+			UPDATE users SET deleted_at = NOW() WHERE email = $1 RETURNING id
+		`, email).Scan(&userID)
+		s.Require().NoError(err)
+
+		userRepo := repositories.NewPostgresUserRepository(s.db)
+		err = userRepo.PurgeUser(userID, time.Now().Add(-time.Hour))
+		s.ErrorIs(err, domain.ErrUserNotFound)
+
+		var count int
+		err = s.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM users WHERE id = $1`, userID).Scan(&count)
+		s.Require().NoError(err)
+		s.Equal(1, count, "Expected the user to be kept")
+	})
+}
+
+func (s *TestSuite) TestDataExport() {
+	s.Run("should generate an archive downloadable through a signed link", func() {
+		email := "exporter@example.com"
+		s.createTestUser("Exporter", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		var body struct {
+			Status      string `json:"status"`
+			DownloadUrl string `json:"download_url"`
+		}
+
+		s.Require().Eventually(func() bool {
+			req, _ := http.NewRequest("GET", "/api/user/export", nil)
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			s.Require().Contains([]int{http.StatusOK, http.StatusAccepted}, w.Code)
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+
+			return body.Status == "ready"
+		}, 5*time.Second, 50*time.Millisecond, "Expected the export to become ready")
+
+		downloadUrl, err := url.Parse(body.DownloadUrl)
+		s.Require().NoError(err)
+
+		req, _ := http.NewRequest("GET", downloadUrl.RequestURI(), nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		s.Require().NoError(err)
+
+		files := map[string]bool{}
+		for _, file := range archive.File {
+			files[file.Name] = true
+		}
+		s.True(files["manifest.json"])
+		s.True(files["user.json"])
+		s.True(files["social_accounts.json"])
+		s.True(files["mfa.json"])
+		s.True(files["passkeys.json"])
+		s.True(files["magic_links.json"])
+		s.True(files["account_unlock_tokens.json"])
+
+		manifestFile, err := archive.Open("manifest.json")
+		s.Require().NoError(err)
+		defer manifestFile.Close()
+
+		var manifest struct {
+			NotExported []struct {
+				Data string `json:"data"`
+			} `json:"not_exported"`
+		}
+		s.Require().NoError(json.NewDecoder(manifestFile).Decode(&manifest))
+
+		notExported := []string{}
+		for _, omission := range manifest.NotExported {
+			notExported = append(notExported, omission.Data)
+		}
+		s.Contains(notExported, "consents", "Expected the manifest to tell that consents are not exported")
+		s.Contains(notExported, "audit_events", "Expected the manifest to tell that audit events are not exported")
+	})
+
+	s.Run("should reject a tampered download link", func() {
+		req, _ := http.NewRequest("GET", "/api/user/export/download?token=invalid-token", nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		s.Equal(http.StatusForbidden, w.Code, "Expected status code 403 Forbidden")
+	})
+}
+
+func (s *TestSuite) TestTOTP() {
+	s.Run("should require a second factor once enabled", func() {
+		email := "mfa@example.com"
+		s.createTestUser("MFA", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("POST", "/api/user/mfa/totp", nil)
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var enrollment struct {
+			Secret     string `json:"secret"`
+			OtpauthURI string `json:"otpauth_uri"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&enrollment))
+		s.Contains(enrollment.OtpauthURI, "otpauth://totp/")
+
+		code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+		s.Require().NoError(err)
+
+		req, _ = http.NewRequest("POST", "/api/user/mfa/totp/confirm", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, code)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var confirmation struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&confirmation))
+		s.Require().Len(confirmation.RecoveryCodes, 10)
+
+		req, _ = http.NewRequest("POST", "/api/login", strings.NewReader(fmt.Sprintf(`{"email": "%s", "password": "f205c9241173"}`, email)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+		s.saveCookies(w)
+
+		var body map[string]string
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("mfa_required", body["code"])
+
+		// The pending login is not signed in yet.
+		req, _ = http.NewRequest("GET", "/api/user", nil)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+
+		// The code used for confirmation cannot be replayed.
+		req, _ = http.NewRequest("POST", "/api/login/mfa", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, code)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+
+		req, _ = http.NewRequest("POST", "/api/login/mfa", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, confirmation.RecoveryCodes[0])))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		user := s.getTestUser()
+		s.Equal(true, user["mfa_enabled"])
+	})
+
+	s.Run("should reject an invalid confirmation code", func() {
+		email := "mfa-2@example.com"
+		s.createTestUser("MFA", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("POST", "/api/user/mfa/totp", nil)
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		req, _ = http.NewRequest("POST", "/api/user/mfa/totp/confirm", strings.NewReader(`{"code": "000000x"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_MFA_CODE", body["code"])
+	})
+}
+
+func (s *TestSuite) TestPasskey() {
+	s.Run("should issue registration options and reject an invalid attestation", func() {
+		email := "passkey@example.com"
+		s.createTestUser("Passkey", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		req, _ := http.NewRequest("POST", "/api/user/passkeys/register/begin", nil)
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var options struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+				User      struct {
+					Name string `json:"name"`
+				} `json:"user"`
+			} `json:"publicKey"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&options))
+		s.NotEmpty(options.PublicKey.Challenge)
+		s.Equal(email, options.PublicKey.User.Name)
+
+		req, _ = http.NewRequest("POST", "/api/user/passkeys/register/finish", strings.NewReader(`{"name": "Laptop", "credential": {"id": "invalid"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("PASSKEY_FAILED", body["code"])
+
+		req, _ = http.NewRequest("GET", "/api/user/passkeys", nil)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+		s.JSONEq(`[]`, w.Body.String())
+	})
+
+	s.Run("should reject a passkey login without a started ceremony", func() {
+		req, _ := http.NewRequest("POST", "/api/login/passkey/finish", strings.NewReader(`{"credential": {"id": "invalid"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+	})
+}
+
+func (s *TestSuite) TestMagicLink() {
+	s.Run("should sign in only the requesting browser, once", func() {
+		email := "magic@example.com"
+		s.createTestUser("Magic", email, "f205c9241173")
+
+		req, _ := http.NewRequest("POST", "/api/login/magic-link", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal(email, messages[0].To)
+
+		matches := regexp.MustCompile(`/template/login/magic-link\?token=(\S+)`).FindStringSubmatch(messages[0].TextBody)
+		s.Require().Len(matches, 2, "Expected a login link in the mail body")
+		token, err := url.QueryUnescape(matches[1])
+		s.Require().NoError(err)
+
+		verifyPayload := fmt.Sprintf(`{"token": "%s"}`, token)
+
+		// Another browser has its own session and CSRF token.
+		req, _ = http.NewRequest("GET", "/api/csrf-token", nil)
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code)
+		otherCSRFToken, otherCookies := w.Header().Get("X-CSRF-Token"), w.Result().Cookies()
+
+		req, _ = http.NewRequest("POST", "/api/login/magic-link/verify", strings.NewReader(verifyPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", otherCSRFToken)
+
+		for _, cookie := range otherCookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusForbidden, w.Code, "Expected status code 403 Forbidden")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("MAGIC_LINK_DEVICE_MISMATCH", body["code"])
+
+		req, _ = http.NewRequest("POST", "/api/login/magic-link/verify", strings.NewReader(verifyPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		user := s.getTestUser()
+		s.Equal(email, user["email"])
+
+		req, _ = http.NewRequest("POST", "/api/login/magic-link/verify", strings.NewReader(verifyPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusBadRequest, w.Code, "Expected the link to be single use")
+
+		body = map[string]string{}
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_MAGIC_LINK", body["code"])
+	})
+
+	s.Run("should throttle magic link requests per client IP", func() {
+		limit := config.AppConfig.LoginIPFailureLimit
+		config.AppConfig.LoginIPFailureLimit = 2
+		defer func() { config.AppConfig.LoginIPFailureLimit = limit }()
+
+		request := func(email string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("POST", "/api/login/magic-link", strings.NewReader(fmt.Sprintf(`{"email": "%s"}`, email)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-CSRF-Token", s.csrfToken)
+			// An IP of its own, apart from the requests of the other subtests
+			req.RemoteAddr = "198.51.100.7:40000"
+
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			return w
+		}
+
+		// Requests for different emails still count against the same IP.
+		s.Equal(http.StatusNoContent, request("flood-1@example.com").Code)
+		s.Equal(http.StatusNoContent, request("flood-2@example.com").Code)
+
+		w := request("flood-3@example.com")
+		s.Equal(http.StatusTooManyRequests, w.Code, "Expected status code 429 Too Many Requests")
+		s.NotEmpty(w.Header().Get("Retry-After"))
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("TOO_MANY_ATTEMPTS", body["code"])
+	})
+}
+
+func (s *TestSuite) TestAccountLockout() {
+	s.Run("should lock the account after repeated failures until unlocked by email", func() {
+		threshold := config.AppConfig.LoginLockoutThreshold
+		config.AppConfig.LoginLockoutThreshold = 3
+		defer func() { config.AppConfig.LoginLockoutThreshold = threshold }()
+
+		email := "locked-out@example.com"
+		password := "f205c9241173"
+		s.createTestUser("Locked Out", email, password)
+
+		login := func(password string) *httptest.ResponseRecorder {
+			loginPayload := fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)
+			req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			return w
+		}
+
+		for i := 0; i < 3; i++ {
+			s.Require().Equal(http.StatusUnauthorized, login("wrong-password").Code, "Expected status code 401 Unauthorized")
+		}
+
+		w := login(password)
+		s.Require().Equal(http.StatusLocked, w.Code, "Expected the correct password to be rejected while locked")
+		s.NotEmpty(w.Header().Get("Retry-After"))
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("ACCOUNT_LOCKED", body["code"])
+
+		messages := s.outbox.Messages()
+		s.Require().Len(messages, 1)
+		s.Equal(email, messages[0].To)
+
+		matches := regexp.MustCompile(`/template/account/unlock\?token=(\S+)`).FindStringSubmatch(messages[0].TextBody)
+		s.Require().Len(matches, 2, "Expected an unlock link in the mail body")
+		token, err := url.QueryUnescape(matches[1])
+		s.Require().NoError(err)
+
+		req, _ := http.NewRequest("POST", "/api/account/unlock", strings.NewReader(fmt.Sprintf(`{"token": "%s"}`, token)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		s.loginTestUser(email, password)
+
+		req, _ = http.NewRequest("POST", "/api/account/unlock", strings.NewReader(fmt.Sprintf(`{"token": "%s"}`, token)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusBadRequest, w.Code, "Expected the unlock link to be usable only once")
+
+		body = map[string]string{}
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("INVALID_UNLOCK_TOKEN", body["code"])
+	})
+
+	s.Run("should lock password confirmation after repeated failures on a signed-in session", func() {
+		threshold := config.AppConfig.LoginLockoutThreshold
+		config.AppConfig.LoginLockoutThreshold = 3
+		defer func() { config.AppConfig.LoginLockoutThreshold = threshold }()
+
+		email := "confirm-locked-out@example.com"
+		password := "f205c9241173"
+		s.createTestUser("Confirm Locked Out", email, password)
+		s.loginTestUser(email, password)
+
+		changePassword := func(currentPassword string) *httptest.ResponseRecorder {
+			payload := fmt.Sprintf(`{"current_password": "%s", "password": "a-new-password-8c1f"}`, currentPassword)
+			req, _ := http.NewRequest("PUT", "/api/user/password", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			return w
+		}
+
+		for i := 0; i < 3; i++ {
+			w := changePassword("wrong-password")
+			s.Require().Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+		}
+
+		w := changePassword(password)
+		s.Require().Equal(http.StatusLocked, w.Code, "Expected the correct password to be rejected while locked")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("ACCOUNT_LOCKED", body["code"])
+
+		// Deleting the account checks the password against the same limits.
+		req, _ := http.NewRequest("DELETE", "/api/user", strings.NewReader(fmt.Sprintf(`{"password": "%s"}`, password)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusLocked, w.Code, "Expected the account deletion to be locked as well")
+	})
+
+	for _, store := range []string{"postgres", "memory"} {
+		s.Run("should lock the account with failures kept in "+store, func() {
+			threshold := config.AppConfig.LoginLockoutThreshold
+			loginAttemptStore := config.AppConfig.LoginAttemptStore
+			config.AppConfig.LoginLockoutThreshold = 3
+			config.AppConfig.LoginAttemptStore = store
+			defer func() {
+				config.AppConfig.LoginLockoutThreshold = threshold
+				config.AppConfig.LoginAttemptStore = loginAttemptStore
+			}()
+
+			app, cleanup, err := internal.InitializeApp()
+			s.Require().NoError(err)
+			defer cleanup()
+
+			defaultRouter := s.router
+			s.router = app.Router
+			defer func() { s.router = defaultRouter }()
+
+			email := store + "-locked-out@example.com"
+			password := "f205c9241173"
+			s.createTestUser("Locked Out", email, password)
+
+			login := func(password string) int {
+				loginPayload := fmt.Sprintf(`{"email": "%s", "password": "%s"}`, email, password)
+				req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+				for _, cookie := range s.cookies {
+					req.AddCookie(cookie)
+				}
+
+				w := httptest.NewRecorder()
+				s.router.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			for i := 0; i < 3; i++ {
+				s.Require().Equal(http.StatusUnauthorized, login("wrong-password"), "Expected status code 401 Unauthorized")
+			}
+			s.Equal(http.StatusLocked, login(password), "Expected the correct password to be rejected while locked")
+		})
+	}
+}
+
+func (s *TestSuite) TestSessions() {
+	s.Run("should list the sessions of the user and sign out other devices", func() {
+		email := "sessions@example.com"
+		s.createTestUser("Sessions", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		request := func(method, path string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, path, nil)
+			req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+			for _, cookie := range s.cookies {
+				req.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			return w
+		}
+
+		listSessions := func() []map[string]any {
+			w := request("GET", "/api/user/sessions")
+			s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+			var userSessions []map[string]any
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&userSessions))
+			return userSessions
+		}
+
+		addOtherSession := func() string {
+			var id string
+			err := s.db.QueryRow(context.Background(), `
+				INSERT INTO user_sessions (id, user_id, device, ip_address, user_agent)
+				SELECT gen_random_uuid(), id, 'Firefox on Linux', '203.0.113.7', 'Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0'
+				FROM users WHERE email = $1
+				RETURNING id
+			`, email).Scan(&id)
+			s.Require().NoError(err, "Failed to insert session")
+			return id
+		}
+
+		userSessions := listSessions()
+		s.Require().Len(userSessions, 1)
+		s.Equal(true, userSessions[0]["current"])
+		currentID := userSessions[0]["id"].(string)
+
+		otherID := addOtherSession()
+		s.Len(listSessions(), 2)
+
+		s.Equal(http.StatusNoContent, request("DELETE", "/api/user/sessions/"+otherID).Code, "Expected status code 204 No Content")
+		s.Len(listSessions(), 1)
+
+		w := request("DELETE", "/api/user/sessions/"+otherID)
+		s.Equal(http.StatusNotFound, w.Code, "Expected status code 404 Not Found")
+
+		var body map[string]string
+		s.NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("SESSION_NOT_FOUND", body["code"])
+
+		addOtherSession()
+		addOtherSession()
+		s.Equal(http.StatusNoContent, request("DELETE", "/api/user/sessions").Code, "Expected status code 204 No Content")
+
+		userSessions = listSessions()
+		s.Require().Len(userSessions, 1)
+		s.Equal(currentID, userSessions[0]["id"])
+	})
+
+	s.Run("should sign out a session removed from the index", func() {
+		email := "sessions-2@example.com"
+		s.createTestUser("Sessions", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		_, err := s.db.Exec(context.Background(), `
+			DELETE FROM user_sessions WHERE user_id = (SELECT id FROM users WHERE email = $1)
+		`, email)
+		s.Require().NoError(err, "Failed to delete sessions")
+
+		req, _ := http.NewRequest("GET", "/api/user", nil)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+	})
+}
+
+func (s *TestSuite) TestSessionSecurity() {
+	getUser := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/user", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	withoutSessionCookie := func() []*http.Cookie {
+		return slices.DeleteFunc(slices.Clone(s.cookies), func(cookie *http.Cookie) bool {
+			return cookie.Name == "usersession"
+		})
+	}
+
+	s.Run("should rotate the session ID on login", func() {
+		email := "rotation@example.com"
+		s.createTestUser("Rotation", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+		before := slices.Clone(s.cookies)
+
+		s.loginTestUser(email, "f205c9241173")
+
+		s.Equal(http.StatusUnauthorized, getUser(before).Code, "Expected the previous session ID to be signed out")
+		s.Equal(http.StatusOK, getUser(s.cookies).Code, "Expected status code 200 OK")
+	})
+
+	s.Run("should end a session after the idle timeout", func() {
+		idleTimeout := config.AppConfig.SessionIdleTimeout
+		config.AppConfig.SessionIdleTimeout = 50 * time.Millisecond
+		defer func() { config.AppConfig.SessionIdleTimeout = idleTimeout }()
+
+		email := "idle@example.com"
+		s.createTestUser("Idle", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		time.Sleep(100 * time.Millisecond)
+
+		s.Equal(http.StatusUnauthorized, getUser(s.cookies).Code, "Expected status code 401 Unauthorized")
+	})
+
+	s.Run("should sign a remembered device back in until it logs out", func() {
+		email := "remembered@example.com"
+		s.createTestUser("Remembered", email, "f205c9241173")
+
+		loginPayload := fmt.Sprintf(`{"email": "%s", "password": "f205c9241173", "remember_me": true}`, email)
+		req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+		s.Require().True(slices.ContainsFunc(s.cookies, func(cookie *http.Cookie) bool {
+			return cookie.Name == "remember_me"
+		}), "Expected a remember-me cookie")
+
+		// The session cookie is gone, e.g. after the browser was closed.
+		s.cookies = withoutSessionCookie()
+		w = getUser(s.cookies)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected the remembered device to be signed in")
+		s.saveCookies(w)
+
+		req, _ = http.NewRequest("POST", "/api/logout", nil)
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		s.False(slices.ContainsFunc(s.cookies, func(cookie *http.Cookie) bool {
+			return cookie.Name == "remember_me"
+		}), "Expected the remember-me cookie to be dropped")
+		s.Equal(http.StatusUnauthorized, getUser(withoutSessionCookie()).Code, "Expected status code 401 Unauthorized")
+	})
+
+	s.Run("should rotate the remember-me token and revoke the session when an old one is reused", func() {
+		email := "remember-rotation@example.com"
+		s.createTestUser("Remember Rotation", email, "f205c9241173")
+
+		loginPayload := fmt.Sprintf(`{"email": "%s", "password": "f205c9241173", "remember_me": true}`, email)
+		req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		rememberToken := func() string {
+			i := slices.IndexFunc(s.cookies, func(cookie *http.Cookie) bool {
+				return cookie.Name == "remember_me"
+			})
+			s.Require().NotEqual(-1, i, "Expected a remember-me cookie")
+			return s.cookies[i].Value
+		}
+
+		stolen := withoutSessionCookie()
+		oldToken := rememberToken()
+
+		s.cookies = withoutSessionCookie()
+		w = getUser(s.cookies)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected the remembered device to be signed in")
+		s.saveCookies(w)
+		s.NotEqual(oldToken, rememberToken(), "Expected the remember-me token to be rotated")
+
+		// Past the grace period for parallel requests.
+		_, err := s.db.Exec(context.Background(), `UPDATE retired_remember_tokens SET retired_at = NOW() - INTERVAL '1 minute'`)
+		s.Require().NoError(err)
+
+		s.Equal(http.StatusUnauthorized, getUser(stolen).Code, "Expected the rotated token to be rejected")
+		s.Equal(http.StatusUnauthorized, getUser(withoutSessionCookie()).Code, "Expected the session to be revoked after the reuse")
+
+		var count int
+		err = s.db.QueryRow(context.Background(), `
+			SELECT COUNT(*) FROM user_sessions WHERE user_id = (SELECT id FROM users WHERE email = $1)
+		`, email).Scan(&count)
+		s.Require().NoError(err)
+		s.Zero(count)
+	})
+
+	s.Run("should resume parallel requests sent with the same remember-me token", func() {
+		email := "remember-parallel@example.com"
+		s.createTestUser("Remember Parallel", email, "f205c9241173")
+
+		loginPayload := fmt.Sprintf(`{"email": "%s", "password": "f205c9241173", "remember_me": true}`, email)
+		req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(loginPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+		s.saveCookies(w)
+
+		// Both requests carry the cookie from before either was answered.
+		cookies := withoutSessionCookie()
+
+		first := getUser(cookies)
+		s.Require().Equal(http.StatusOK, first.Code, "Expected the first request to be signed in")
+
+		second := getUser(cookies)
+		s.Require().Equal(http.StatusOK, second.Code, "Expected the parallel request to be signed in")
+		s.False(slices.ContainsFunc(second.Result().Cookies(), func(cookie *http.Cookie) bool {
+			return cookie.Name == "remember_me"
+		}), "Expected the parallel request to keep the cookie set by the first")
+
+		s.saveCookies(first)
+		s.saveCookies(second)
+		s.cookies = withoutSessionCookie()
+		s.Equal(http.StatusOK, getUser(s.cookies).Code, "Expected the device to stay remembered")
+	})
+}
+
+func (s *TestSuite) TestReauthentication() {
+	reauthWindow := config.AppConfig.ReauthWindow
+	config.AppConfig.ReauthWindow = 50 * time.Millisecond
+	defer func() { config.AppConfig.ReauthWindow = reauthWindow }()
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.saveCookies(w)
+		return w
+	}
+
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		return body["code"].(string)
+	}
+
+	s.Run("should require a recent authentication to change the email but not the name", func() {
+		email := "stale-profile@example.com"
+		s.createTestUser("Stale Profile", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+		time.Sleep(100 * time.Millisecond)
+
+		user := s.getTestUser()
+		w := send("PATCH", "/api/user", fmt.Sprintf(`{"name": "Renamed", "updated_at": "%s"}`, user["updated_at"]))
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		user = s.getTestUser()
+		w = send("PATCH", "/api/user", fmt.Sprintf(`{"email": "fresh-profile@example.com", "updated_at": "%s"}`, user["updated_at"]))
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+		s.Equal("REAUTH_REQUIRED", errorCode(w))
+	})
+
+	s.Run("should allow deleting the account after re-authenticating with the password", func() {
+		email := "stale-delete@example.com"
+		s.createTestUser("Stale Delete", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+		time.Sleep(100 * time.Millisecond)
+
+		w := send("DELETE", "/api/user", `{"password": "f205c9241173"}`)
+		s.Require().Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+		s.Equal("REAUTH_REQUIRED", errorCode(w))
+
+		w = send("POST", "/api/user/reauth", `{"password": "wrong-password"}`)
+		s.Require().Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+		s.Equal("INCORRECT_PASSWORD", errorCode(w))
+
+		w = send("POST", "/api/user/reauth", `{"password": "f205c9241173"}`)
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		w = send("DELETE", "/api/user", `{"password": "f205c9241173"}`)
+		s.Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+	})
+
+	s.Run("should require a recent authentication to unlink a social account", func() {
+		email := "stale-unlink@example.com"
+		s.createTestUser("Stale Unlink", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+		time.Sleep(100 * time.Millisecond)
+
+		w := send("DELETE", "/api/user/unlink/google", "")
+		s.Equal(http.StatusUnauthorized, w.Code, "Expected status code 401 Unauthorized")
+		s.Equal("REAUTH_REQUIRED", errorCode(w))
+	})
+}
+
+func (s *TestSuite) TestLoginMethods() {
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	linkGoogleAccount := func(email, providerUserID string) {
+		_, err := s.db.Exec(context.Background(), `
+			INSERT INTO social_accounts (user_id, provider, provider_user_id)
+			SELECT id, 'google', $2 FROM users WHERE email = $1
+		`, email, providerUserID)
+		s.Require().NoError(err)
+	}
+
+	s.Run("should list the login methods and unlink a provider while another remains", func() {
+		email := "methods@example.com"
+		s.createTestUser("Methods", email, "f205c9241173")
+		linkGoogleAccount(email, "methods-google-id")
+		s.loginTestUser(email, "f205c9241173")
+
+		_, err := s.db.Exec(context.Background(), `UPDATE users SET email_verified_at = NOW() WHERE email = $1`, email)
+		s.Require().NoError(err)
+
+		w := send("GET", "/api/user/login-methods")
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		var methods struct {
+			Password      bool     `json:"password"`
+			EmailVerified bool     `json:"email_verified"`
+			Providers     []string `json:"providers"`
+			Passkeys      int      `json:"passkeys"`
+		}
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&methods))
+		s.True(methods.Password)
+		s.True(methods.EmailVerified)
+		s.Equal([]string{"google"}, methods.Providers)
+		s.Zero(methods.Passkeys)
+
+		w = send("DELETE", "/api/user/unlink/google")
+		s.Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+	})
+
+	s.Run("should not count a password without a verified email", func() {
+		email := "unverified-method@example.com"
+		s.createTestUser("Unverified Method", email, "f205c9241173")
+		linkGoogleAccount(email, "unverified-method-google-id")
+		s.loginTestUser(email, "f205c9241173")
+
+		w := send("DELETE", "/api/user/unlink/google")
+		s.Require().Equal(http.StatusConflict, w.Code, "Expected status code 409 Conflict")
+
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("LAST_LOGIN_METHOD", body["code"])
+	})
+
+	s.Run("should refuse to unlink the last login method", func() {
+		email := "last-method@example.com"
+		s.createTestUser("Last Method", email, "f205c9241173")
+		linkGoogleAccount(email, "last-method-google-id")
+		s.loginTestUser(email, "f205c9241173")
+
+		// The user is left with the social account only.
+		_, err := s.db.Exec(context.Background(), `UPDATE users SET password = NULL WHERE email = $1`, email)
+		s.Require().NoError(err)
+
+		w := send("DELETE", "/api/user/unlink/google")
+		s.Require().Equal(http.StatusConflict, w.Code, "Expected status code 409 Conflict")
+
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		s.Equal("LAST_LOGIN_METHOD", body["code"])
+
+		var count int
+		err = s.db.QueryRow(context.Background(), `
+			SELECT COUNT(*) FROM social_accounts WHERE provider_user_id = 'last-method-google-id' AND user_id IS NOT NULL
+		`).Scan(&count)
+		s.Require().NoError(err)
+		s.Equal(1, count, "Expected the social account to stay linked")
+	})
+}
+
+func (s *TestSuite) TestSocialLinkTokens() {
+	email := "pending-link@example.com"
+	s.createTestUser("Pending Link", email, "f205c9241173")
+
+	var socialAccountID int64
+	err := s.db.QueryRow(context.Background(), `
+		INSERT INTO social_accounts (provider, provider_user_id, email, email_verified)
+		VALUES ('facebook', 'pending-link-facebook-id', $1, TRUE)
+		RETURNING id
+	`, email).Scan(&socialAccountID)
+	s.Require().NoError(err)
+
+	createLinkToken := func(token string, expiresAt time.Time, consumed bool) {
+		tokenHash := sha256.Sum256([]byte(token))
+
+		_, err := s.db.Exec(context.Background(), `
+			INSERT INTO social_link_tokens (id, token_hash, user_id, social_account_id, expires_at, consumed_at)
+			SELECT gen_random_uuid(), $1, id, $2, $3, CASE WHEN $4 THEN NOW() END FROM users WHERE email = $5
+		`, hex.EncodeToString(tokenHash[:]), socialAccountID, expiresAt, consumed, email)
+		s.Require().NoError(err)
+	}
+
+	linkAuthUrl := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/auth/social/google/link/url?redirect_uri=http://localhost/callback&link_token="+url.QueryEscape(token), nil)
+		for _, cookie := range s.cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	createLinkToken("valid-link-token", time.Now().Add(time.Minute), false)
+	createLinkToken("consumed-link-token", time.Now().Add(time.Minute), true)
+	createLinkToken("expired-link-token", time.Now().Add(-time.Minute), false)
+
+	s.Run("should accept a pending link token", func() {
+		w := linkAuthUrl("valid-link-token")
+		s.Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+	})
+
+	for _, token := range []string{"consumed-link-token", "expired-link-token", "unknown-link-token"} {
+		s.Run("should reject the "+token, func() {
+			w := linkAuthUrl(token)
+			s.Require().Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+
+			var body map[string]any
+			s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+			s.Equal("INVALID_LINK_TOKEN", body["code"])
+		})
+	}
+
+	_, err = s.db.Exec(context.Background(), `
+		INSERT INTO social_accounts (user_id, provider, provider_user_id)
+		SELECT id, 'google', 'linked-google-id' FROM users WHERE email = $1
+	`, email)
+	s.Require().NoError(err)
+
+	_, err = s.db.Exec(context.Background(), `
+		INSERT INTO social_accounts (provider, provider_user_id) VALUES ('google', 'unlinked-google-id')
+	`)
+	s.Require().NoError(err)
+
+	// startLink gets a link URL in the browser with the given cookies and
+	// returns the browser's cookies with the state and nonce to complete it.
+	startLink := func(cookies []*http.Cookie, token string) ([]*http.Cookie, string, string) {
+		req, _ := http.NewRequest("GET", "/api/auth/social/google/link/url?redirect_uri=http://localhost/callback&link_token="+url.QueryEscape(token), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		s.Require().Equal(http.StatusOK, w.Code, "Expected status code 200 OK")
+
+		for _, cookie := range w.Result().Cookies() {
+			cookies = slices.DeleteFunc(slices.Clone(cookies), func(saved *http.Cookie) bool {
+				return saved.Name == cookie.Name
+			})
+			cookies = append(cookies, cookie)
+		}
+
+		var body map[string]string
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		authUrl, err := url.Parse(body["link_auth_url"])
+		s.Require().NoError(err)
+
+		return cookies, authUrl.Query().Get("state"), authUrl.Query().Get("nonce")
+	}
+
+	// completeLink posts the provider callback, confirming the link with the
+	// Google account providerUserID.
+	completeLink := func(cookies []*http.Cookie, state, nonce, token, providerUserID string) *httptest.ResponseRecorder {
+		restore := fakeGoogleTokenExchange(providerUserID, "", false)
+		defer restore()
+
+		payload := fmt.Sprintf(
+			`{"provider": "google", "code": "%s", "state": "%s", "link_token": "%s", "redirect_uri": "http://localhost/callback"}`,
+			nonce, state, token,
+		)
+		req, _ := http.NewRequest("POST", "/api/auth/social/link", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-CSRF-Token", s.csrfToken)
+
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var body map[string]any
+		s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+		code, _ := body["code"].(string)
+		return code
+	}
+
+	withoutSession := func(cookies []*http.Cookie) []*http.Cookie {
+		return slices.DeleteFunc(slices.Clone(cookies), func(cookie *http.Cookie) bool {
+			return cookie.Name == "usersession"
+		})
+	}
+
+	s.Run("should reject confirming the link with a social account linked to no user", func() {
+		createLinkToken("unlinked-confirm-link-token", time.Now().Add(time.Minute), false)
+
+		cookies, state, nonce := startLink(withoutSession(s.cookies), "unlinked-confirm-link-token")
+		w := completeLink(cookies, state, nonce, "unlinked-confirm-link-token", "unlinked-google-id")
+
+		s.Equal(http.StatusConflict, w.Code, "Expected status code 409 Conflict")
+		s.Equal("MISMATCHED_LINKED_USER", errorCode(w))
+	})
+
+	s.Run("should link once and reject a second link with the same token", func() {
+		createLinkToken("single-use-link-token", time.Now().Add(time.Minute), false)
+
+		// Two browsers start the link before either completes it.
+		first, firstState, firstNonce := startLink(withoutSession(s.cookies), "single-use-link-token")
+		second, secondState, secondNonce := startLink(withoutSession(s.cookies), "single-use-link-token")
+
+		w := completeLink(first, firstState, firstNonce, "single-use-link-token", "linked-google-id")
+		s.Require().Equal(http.StatusNoContent, w.Code, "Expected status code 204 No Content")
+
+		w = completeLink(second, secondState, secondNonce, "single-use-link-token", "linked-google-id")
+		s.Equal(http.StatusBadRequest, w.Code, "Expected status code 400 Bad Request")
+		s.Equal("INVALID_LINK_TOKEN", errorCode(w))
+
+		var linked bool
+		err := s.db.QueryRow(context.Background(), `
+			SELECT user_id = (SELECT id FROM users WHERE email = $1) FROM social_accounts WHERE id = $2
+		`, email, socialAccountID).Scan(&linked)
+		s.Require().NoError(err)
+		s.True(linked, "Expected the pending social account to be linked")
+	})
+}
+
+// fakeGoogleTokenExchange answers Google token exchanges with an id_token for
+// providerUserID until the returned func is called. The id_token carries the
+// email claims unless email is empty. The authorization code is echoed as the
+// nonce, so callers pass the nonce of the auth URL as the code.
+func fakeGoogleTokenExchange(providerUserID, email string, emailVerified bool) func() {
+	transport := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != "oauth2.googleapis.com" {
+			return transport.RoundTrip(req)
+		}
+
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+
+		claims := jwt.MapClaims{
+			"sub":   providerUserID,
+			"name":  "Google User",
+			"nonce": req.PostForm.Get("code"),
+		}
+		if email != "" {
+			claims["email"] = email
+			claims["email_verified"] = emailVerified
+		}
+
+		idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test"))
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := json.Marshal(map[string]any{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	return func() { http.DefaultTransport = transport }
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (s *TestSuite) TestMailDriver() {
+	mailDriver := config.AppConfig.MailDriver
+	defer func() { config.AppConfig.MailDriver = mailDriver }()
+
+	for _, driver := range []string{"", "unknown"} {
+		s.Run("should fail at startup with the mail driver "+strconv.Quote(driver), func() {
+			config.AppConfig.MailDriver = driver
+
+			_, _, err := internal.InitializeApp()
+			s.Error(err, "Expected an error for a missing or unknown mail driver")
+		})
+	}
+}
+
+func (s *TestSuite) TestSessionStores() {
+	sessionStore := config.AppConfig.SessionStore
+	defer func() { config.AppConfig.SessionStore = sessionStore }()
+
+	s.Run("should fail at startup with an unknown session store", func() {
+		config.AppConfig.SessionStore = "unknown"
+
+		_, _, err := internal.InitializeApp()
+		s.Error(err, "Expected an error for an unknown session store")
+	})
+
+	s.Run("should keep sessions in Postgres", func() {
+		config.AppConfig.SessionStore = "postgres"
+
+		app, cleanup, err := internal.InitializeApp()
+		s.Require().NoError(err)
+		defer cleanup()
+
+		defaultRouter := s.router
+		s.router = app.Router
+		defer func() { s.router = defaultRouter }()
+
+		email := "postgres-session@example.com"
+		s.createTestUser("Postgres Session", email, "f205c9241173")
+		s.loginTestUser(email, "f205c9241173")
+
+		var count int
+		err = s.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM http_sessions`).Scan(&count)
+		s.Require().NoError(err)
+		s.NotZero(count, "Expected the session to be stored in Postgres")
+
+		user := s.getTestUser()
+		s.Equal(email, user["email"])
+	})
+}
+
 func TestAPISuite(t *testing.T) {
 	suite.Run(t, new(TestSuite))
 }
@@ -31,6 +31,21 @@ axiosInstance.interceptors.response.use(
   }
 );
 
+const passwordErrorMessages = {
+    PASSWORD_TOO_SHORT: '密碼長度不足',
+    PASSWORD_TOO_LONG: '密碼長度過長',
+    PASSWORD_TOO_SIMPLE: '密碼需混合大小寫字母、數字或符號',
+    PASSWORD_BANNED: '密碼過於常見',
+    PASSWORD_SIMILAR_TO_ACCOUNT: '密碼不可包含 Email 或名稱',
+    PASSWORD_BREACHED: '此密碼曾出現在外洩資料中，請改用其他密碼',
+};
+
+function passwordErrorMessage(error) {
+    return (error.response.data.errors || [])
+        .map(fieldError => passwordErrorMessages[fieldError.code] || fieldError.message)
+        .join('\n');
+}
+
 function getCSRFToken() {
     return axiosInstance.get('/csrf-token')
         .then(response => {
@@ -55,8 +70,8 @@ function register(userData) {
         });
 }
 
-function loginWithEmail(email, password) {
-    return axiosInstance.post('/login', { email, password })
+function loginWithEmail(email, password, rememberMe = false) {
+    return axiosInstance.post('/login', { email, password, remember_me: rememberMe })
         .then(response => response.data)
         .catch(error => {
             console.error("Error logging in:", error);
@@ -64,6 +79,341 @@ function loginWithEmail(email, password) {
         });
 }
 
+function requestMagicLink(email) {
+    return axiosInstance.post('/login/magic-link', { email })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error requesting login link:", error);
+            throw error;
+        });
+}
+
+function verifyMagicLink(token) {
+    return axiosInstance.post('/login/magic-link/verify', { token })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error verifying login link:", error);
+            throw error;
+        });
+}
+
+function verifyLoginMFA(code) {
+    return axiosInstance.post('/login/mfa', { code })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error verifying two-factor code:", error);
+            throw error;
+        });
+}
+
+function getMFAStatus() {
+    return axiosInstance.get('/user/mfa')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error getting two-factor status:", error);
+            throw error;
+        });
+}
+
+function enrollTOTP() {
+    return axiosInstance.post('/user/mfa/totp')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error enrolling authenticator app:", error);
+            throw error;
+        });
+}
+
+function confirmTOTP(code) {
+    return axiosInstance.post('/user/mfa/totp/confirm', { code })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error confirming authenticator app:", error);
+            throw error;
+        });
+}
+
+function disableTOTP(code) {
+    return axiosInstance.delete('/user/mfa/totp', { data: { code } })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error disabling two-factor authentication:", error);
+            throw error;
+        });
+}
+
+function regenerateRecoveryCodes(code) {
+    return axiosInstance.post('/user/mfa/recovery-codes', { code })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error regenerating recovery codes:", error);
+            throw error;
+        });
+}
+
+function base64UrlToBuffer(value) {
+    const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    const padded = base64 + '='.repeat((4 - base64.length % 4) % 4);
+    return Uint8Array.from(atob(padded), c => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64Url(buffer) {
+    const bytes = String.fromCharCode(...new Uint8Array(buffer));
+    return btoa(bytes).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function decodeRequestOptions(options) {
+    const publicKey = options.publicKey;
+    publicKey.challenge = base64UrlToBuffer(publicKey.challenge);
+    (publicKey.allowCredentials || []).forEach(credential => credential.id = base64UrlToBuffer(credential.id));
+    return publicKey;
+}
+
+function encodeAssertion(credential) {
+    return {
+        id: credential.id,
+        rawId: bufferToBase64Url(credential.rawId),
+        type: credential.type,
+        response: {
+            authenticatorData: bufferToBase64Url(credential.response.authenticatorData),
+            clientDataJSON: bufferToBase64Url(credential.response.clientDataJSON),
+            signature: bufferToBase64Url(credential.response.signature),
+            userHandle: credential.response.userHandle ? bufferToBase64Url(credential.response.userHandle) : null,
+        },
+    };
+}
+
+function registerPasskey(name) {
+    return axiosInstance.post('/user/passkeys/register/begin')
+        .then(response => {
+            const publicKey = response.data.publicKey;
+            publicKey.challenge = base64UrlToBuffer(publicKey.challenge);
+            publicKey.user.id = base64UrlToBuffer(publicKey.user.id);
+            (publicKey.excludeCredentials || []).forEach(credential => credential.id = base64UrlToBuffer(credential.id));
+            return navigator.credentials.create({ publicKey });
+        })
+        .then(credential => axiosInstance.post('/user/passkeys/register/finish', {
+            name,
+            credential: {
+                id: credential.id,
+                rawId: bufferToBase64Url(credential.rawId),
+                type: credential.type,
+                response: {
+                    attestationObject: bufferToBase64Url(credential.response.attestationObject),
+                    clientDataJSON: bufferToBase64Url(credential.response.clientDataJSON),
+                    transports: credential.response.getTransports ? credential.response.getTransports() : [],
+                },
+            },
+        }))
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error registering passkey:", error);
+            throw error;
+        });
+}
+
+function loginWithPasskey() {
+    return axiosInstance.post('/login/passkey/begin')
+        .then(response => navigator.credentials.get({ publicKey: decodeRequestOptions(response.data) }))
+        .then(credential => axiosInstance.post('/login/passkey/finish', { credential: encodeAssertion(credential) }))
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error logging in with passkey:", error);
+            throw error;
+        });
+}
+
+function verifyLoginMFAWithPasskey() {
+    return axiosInstance.post('/login/mfa/passkey/begin')
+        .then(response => navigator.credentials.get({ publicKey: decodeRequestOptions(response.data) }))
+        .then(credential => axiosInstance.post('/login/mfa/passkey/finish', { credential: encodeAssertion(credential) }))
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error verifying passkey:", error);
+            throw error;
+        });
+}
+
+function listPasskeys() {
+    return axiosInstance.get('/user/passkeys')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error listing passkeys:", error);
+            throw error;
+        });
+}
+
+function renamePasskey(id, name) {
+    return axiosInstance.patch(`/user/passkeys/${id}`, { name })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error renaming passkey:", error);
+            throw error;
+        });
+}
+
+function deletePasskey(id) {
+    return axiosInstance.delete(`/user/passkeys/${id}`)
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error deleting passkey:", error);
+            throw error;
+        });
+}
+
+function listSessions() {
+    return axiosInstance.get('/user/sessions')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error listing sessions:", error);
+            throw error;
+        });
+}
+
+function revokeSession(id) {
+    return axiosInstance.delete(`/user/sessions/${id}`)
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error revoking session:", error);
+            throw error;
+        });
+}
+
+function revokeOtherSessions() {
+    return axiosInstance.delete('/user/sessions')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error revoking other sessions:", error);
+            throw error;
+        });
+}
+
+function verifyEmail(token) {
+    return axiosInstance.post('/email/verify', { token })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error verifying email:", error);
+            throw error;
+        });
+}
+
+function resendVerificationEmail(email) {
+    return axiosInstance.post('/email/verify/resend', { email })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error resending verification email:", error);
+            throw error;
+        });
+}
+
+function forgotPassword(email) {
+    return axiosInstance.post('/password/forgot', { email })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error requesting password reset:", error);
+            throw error;
+        });
+}
+
+function resetPassword(token, password) {
+    return axiosInstance.post('/password/reset', { token, password })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error resetting password:", error);
+            throw error;
+        });
+}
+
+function unlockAccount(token) {
+    return axiosInstance.post('/account/unlock', { token })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error unlocking account:", error);
+            throw error;
+        });
+}
+
+function updateUser(changes) {
+    return axiosInstance.patch('/user', changes)
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error updating user:", error);
+            throw error;
+        });
+}
+
+function changePassword(currentPassword, password) {
+    return axiosInstance.put('/user/password', { current_password: currentPassword, password })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error changing password:", error);
+            throw error;
+        });
+}
+
+function getLoginMethods() {
+    return axiosInstance.get('/user/login-methods')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error getting login methods:", error);
+            throw error;
+        });
+}
+
+function reauthenticate(password) {
+    return axiosInstance.post('/user/reauth', { password })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error re-authenticating:", error);
+            throw error;
+        });
+}
+
+function reauthenticateWithMFA(code) {
+    return axiosInstance.post('/user/reauth/mfa', { code })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error re-authenticating with two-factor code:", error);
+            throw error;
+        });
+}
+
+function getSocialAuthUrlForReauth(provider) {
+    return axiosInstance.get(`/user/reauth/social/${provider}/url`)
+        .then(response => response.data)
+        .catch(error => {
+            console.error(`Error getting social auth URL for re-authenticating with ${provider}:`, error);
+            throw error;
+        });
+}
+
+function reauthenticateWithSocialAccount(provider, callbackData) {
+    return axiosInstance.post(`/user/reauth/social/${provider}`, callbackData)
+        .then(response => response.data)
+        .catch(error => {
+            console.error(`Error re-authenticating with ${provider}:`, error);
+            throw error;
+        });
+}
+
+function requestDataExport() {
+    return axiosInstance.get('/user/export')
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error requesting data export:", error);
+            throw error;
+        });
+}
+
+function deleteUser(password) {
+    return axiosInstance.delete('/user', { data: { password } })
+        .then(response => response.data)
+        .catch(error => {
+            console.error("Error deleting user:", error);
+            throw error;
+        });
+}
+
 function logout() {
     axiosInstance.post('/logout')
         .then(() => window.location.href = '/template/login')
@@ -112,8 +462,17 @@ function linkSocialAccount(socialAccountData) {
         });
 }
 
+function getSocialAuthUrlForConnecting(provider) {
+    return axiosInstance.get(`/user/link/${provider}/url`)
+        .then(response => response.data)
+        .catch(error => {
+            console.error(`Error getting social auth URL for connecting ${provider}:`, error);
+            throw error;
+        });
+}
+
 function unlinkSocialAccount(provider) {
-    return axiosInstance.delete('/user/unlink/${provider}')
+    return axiosInstance.delete(`/user/unlink/${provider}`)
         .then(response => response.data)
         .catch(error => {
             console.error(`Error unlinking social account with ${provider}:`, error);